@@ -3,9 +3,13 @@ module github.com/divideandconquer/go-consul-client
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/divideandconquer/go-merge v0.0.0-20150616173453-dc9048d04b65
-	github.com/golang/mock v1.5.0 // indirect
+	github.com/golang/mock v1.5.0
 	github.com/hashicorp/consul v0.6.1-0.20151204164059-71bffe81d1a2
 	github.com/hashicorp/go-cleanhttp v0.0.0-20151022142711-5df5ddc69534 // indirect
+	github.com/hashicorp/hcl v1.0.0
 	github.com/hashicorp/serf v0.6.5-0.20151205003656-e9ac4bb0c572 // indirect
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,88 @@
+package consul
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/divideandconquer/go-consul-client/src/balancer"
+	"github.com/divideandconquer/go-consul-client/src/debugview"
+)
+
+type debugHandler struct {
+	balancer *randomBalancer
+}
+
+// debugService describes the cached instances of a service
+type debugService struct {
+	Name      string          `json:"name"`
+	Instances []debugInstance `json:"instances"`
+	CachedAt  time.Time       `json:"cached_at"`
+	Age       string          `json:"age"`
+	Expired   bool            `json:"expired"`
+}
+
+type debugInstance struct {
+	URL  string `json:"url"`
+	Port int    `json:"port"`
+}
+
+type debugBalancer struct {
+	Environment string         `json:"environment"`
+	TTL         string         `json:"ttl"`
+	Services    []debugService `json:"services"`
+}
+
+// NewDebugHandler returns an http.Handler that renders the services a balancer created by NewRandomDNSBalancer
+// has cached along with how long ago each was looked up.  Responses are json unless html is asked for with
+// ?format=html or an Accept header.
+func NewDebugHandler(b balancer.DNS) (http.Handler, error) {
+	r, ok := b.(*randomBalancer)
+	if !ok {
+		return nil, errors.New("Debug handler requires a balancer created by NewRandomDNSBalancer")
+	}
+	return &debugHandler{balancer: r}, nil
+}
+
+func (d *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	debugview.Render(w, r, debugBalancerTemplate, d.snapshot())
+}
+
+func (d *debugHandler) snapshot() debugBalancer {
+	d.balancer.cacheLock.RLock()
+	defer d.balancer.cacheLock.RUnlock()
+
+	now := time.Now().UTC()
+	view := debugBalancer{Environment: d.balancer.environment, TTL: d.balancer.ttl.String()}
+	for name, c := range d.balancer.cache {
+		instances := make([]debugInstance, 0, len(c.Services))
+		for _, s := range c.Services {
+			instances = append(instances, debugInstance{URL: s.URL, Port: s.Port})
+		}
+		view.Services = append(view.Services, debugService{
+			Name:      name,
+			Instances: instances,
+			CachedAt:  c.CachedAt,
+			Age:       now.Sub(c.CachedAt).Truncate(time.Second).String(),
+			Expired:   !now.Before(c.CachedAt.Add(d.balancer.ttl)),
+		})
+	}
+	sort.Slice(view.Services, func(i, j int) bool { return view.Services[i].Name < view.Services[j].Name })
+	return view
+}
+
+var debugBalancerTemplate = template.Must(template.New("balancer").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Services</title></head>
+<body>
+<h1>Services in {{.Environment}}</h1>
+<p>Cached for {{.TTL}}</p>
+<table border="1">
+<tr><th>Service</th><th>Instances</th><th>Cached at</th><th>Age</th><th>Expired</th></tr>
+{{range .Services}}<tr><td>{{.Name}}</td><td>{{range .Instances}}{{.URL}}:{{.Port}}<br>{{end}}</td><td>{{.CachedAt.Format "2006-01-02T15:04:05Z07:00"}}</td><td>{{.Age}}</td><td>{{.Expired}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,87 @@
+package consul
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/divideandconquer/go-consul-client/src/consulconfig"
+	"github.com/divideandconquer/go-consul-client/src/instrument"
+	"github.com/divideandconquer/go-consul-client/src/logging"
+	"github.com/divideandconquer/go-consul-client/src/retry"
+	"github.com/hashicorp/consul/api"
+)
+
+// Option configures optional behaviour of the balancer created by NewRandomDNSBalancer.
+// Options are applied in order so later options override earlier ones.
+type Option func(*randomBalancer)
+
+// WithRetryPolicy sets how service lookups retry consul when it can't be reached, the default is DefaultRetryPolicy.
+// FindService blocks for as long as the policy keeps retrying.
+func WithRetryPolicy(p retry.Policy) Option {
+	return func(r *randomBalancer) {
+		r.retry = p
+	}
+}
+
+// WithInstrumentation records metrics and spans with instr, the default discards them.
+// The balancer records balancer_cache_lookups_total with a hit, miss or expired result,
+// balancer_consul_requests_total and balancer_consul_request_seconds per request to consul and a
+// balancer_write_service_to_cache span around each refresh of a service.
+func WithInstrumentation(instr instrument.Instrumentation) Option {
+	return func(r *randomBalancer) {
+		r.instr = instr
+	}
+}
+
+// WithLogger logs service lookups and failures to reach consul to logger, the default discards them
+func WithLogger(logger logging.Logger) Option {
+	return func(r *randomBalancer) {
+		r.logger = logger
+	}
+}
+
+// WithConsulClient uses an existing consul client, e.g. one shared with a config loader.
+// The address and every other consul option are ignored.
+func WithConsulClient(client *api.Client) Option {
+	return func(r *randomBalancer) {
+		r.consulClient = client
+	}
+}
+
+// WithConsulConfig builds the consul client from a copy of conf instead of api.DefaultConfig().
+// The consulAddr passed to the constructor is used when conf has no Address.
+func WithConsulConfig(conf *api.Config) Option {
+	return withConsul(consulconfig.Replace(conf))
+}
+
+// WithToken sets the ACL token sent with every request
+func WithToken(token string) Option {
+	return withConsul(consulconfig.Token(token))
+}
+
+// WithDatacenter looks up services in the given datacenter instead of the agent's
+func WithDatacenter(dc string) Option {
+	return withConsul(consulconfig.Datacenter(dc))
+}
+
+// WithHTTPAuth sets the http basic auth credentials sent with every request
+func WithHTTPAuth(username, password string) Option {
+	return withConsul(consulconfig.HTTPAuth(username, password))
+}
+
+// WithHTTPClient sends requests to consul with client, e.g. one shared with a config loader
+func WithHTTPClient(client *http.Client) Option {
+	return withConsul(consulconfig.HTTPClient(client))
+}
+
+// WithTLSConfig talks to consul over https using conf for the client certificates and trusted CAs
+func WithTLSConfig(conf *tls.Config) Option {
+	return withConsul(consulconfig.TLSConfig(conf))
+}
+
+// withConsul applies a consul config option to the config the consul client is built from
+func withConsul(opt consulconfig.Option) Option {
+	return func(r *randomBalancer) {
+		opt(r.consulConfig)
+	}
+}
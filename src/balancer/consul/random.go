@@ -1,6 +1,7 @@
 package consul
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net"
@@ -10,6 +11,9 @@ import (
 	"time"
 
 	"github.com/divideandconquer/go-consul-client/src/balancer"
+	"github.com/divideandconquer/go-consul-client/src/instrument"
+	"github.com/divideandconquer/go-consul-client/src/logging"
+	"github.com/divideandconquer/go-consul-client/src/retry"
 	"github.com/hashicorp/consul/api"
 )
 
@@ -22,8 +26,30 @@ type randomBalancer struct {
 	environment   string
 	consulCatalog *api.Health
 	cache         map[string]cachedServiceLocation
-	cacheLock     sync.RWMutex //TODO lock per serviceName
+	cacheLock     sync.RWMutex
+	fetchLocks    map[string]*sync.Mutex // serializes consul lookups per service
+	fetchLock     sync.Mutex             // guards fetchLocks
 	ttl           time.Duration
+	retry         retry.Policy
+	instr         instrument.Instrumentation
+	logger        logging.Logger
+
+	//only used while constructing the balancer
+	consulConfig *api.Config
+	consulClient *api.Client
+}
+
+// DefaultRetryPolicy is how service lookups retry consul unless WithRetryPolicy is given.  FindService has no
+// context and blocks while an expired or missing service is looked up, so this is much shorter than
+// retry.DefaultPolicy: during a consul outage a lookup fails after about 3 seconds.
+var DefaultRetryPolicy = retry.Policy{
+	InitialInterval: 100 * time.Millisecond,
+	MaxInterval:     time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+	MaxAttempts:     3,
+	MaxElapsed:      3 * time.Second,
+	AttemptTimeout:  time.Second,
 }
 
 func init() {
@@ -31,19 +57,31 @@ func init() {
 }
 
 // NewRandomDNSBalancer will return a random balancer.DNS that looks up dns in consul.
-func NewRandomDNSBalancer(environment string, consulAddr string, cacheTTL time.Duration) (balancer.DNS, error) {
-	config := api.DefaultConfig()
-	config.Address = consulAddr
-	consul, err := api.NewClient(config)
-	if err != nil {
-		return nil, fmt.Errorf("Could not connect to consul: %v", err)
-	}
-
+func NewRandomDNSBalancer(environment string, consulAddr string, cacheTTL time.Duration, opts ...Option) (balancer.DNS, error) {
 	r := randomBalancer{}
 	r.cache = make(map[string]cachedServiceLocation)
+	r.fetchLocks = make(map[string]*sync.Mutex)
 	r.environment = environment
 	r.ttl = cacheTTL
+	r.retry = DefaultRetryPolicy
+	r.instr = instrument.Noop
+	r.logger = logging.Nop
+	r.consulConfig = api.DefaultConfig()
+	r.consulConfig.Address = consulAddr
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	consul := r.consulClient
+	if consul == nil {
+		var err error
+		consul, err = api.NewClient(r.consulConfig)
+		if err != nil {
+			return nil, fmt.Errorf("Could not connect to consul: %v", err)
+		}
+	}
 	r.consulCatalog = consul.Health()
+	r.consulConfig, r.consulClient = nil, nil
 	return &r, nil
 }
 
@@ -86,40 +124,86 @@ func (r *randomBalancer) getServiceFromCache(serviceName string) ([]*balancer.Se
 
 	if result, ok := r.cache[serviceName]; ok {
 		if time.Now().UTC().Before(result.CachedAt.Add(r.ttl)) {
+			r.instr.IncCounter("balancer_cache_lookups_total", instrument.Labels{"service": serviceName, "result": "hit"})
 			return result.Services, nil
 		}
+		r.instr.IncCounter("balancer_cache_lookups_total", instrument.Labels{"service": serviceName, "result": "expired"})
 		return nil, fmt.Errorf("Cache for %s is expired", serviceName)
 	}
+	r.instr.IncCounter("balancer_cache_lookups_total", instrument.Labels{"service": serviceName, "result": "miss"})
 	return nil, fmt.Errorf("Could not find %s in cache", serviceName)
 }
 
-// writeServiceToCache locks specifically to alleviate load on consul some additional lock time
-// is preferable to extra consul calls
-func (r *randomBalancer) writeServiceToCache(serviceName string) ([]*balancer.ServiceLocation, error) {
-	//acquire a write lock
-	r.cacheLock.Lock()
-	defer r.cacheLock.Unlock()
+// healthyServices looks up the passing instances of serviceName returning early if ctx is cancelled
+func (r *randomBalancer) healthyServices(ctx context.Context, serviceName string) ([]*api.ServiceEntry, error) {
+	var services []*api.ServiceEntry
+	err := retry.Call(ctx, func() error {
+		var err error
+		services, _, err = r.consulCatalog.Service(serviceName, r.environment, true, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// serviceLock returns the lock that serializes consul lookups for serviceName
+func (r *randomBalancer) serviceLock(serviceName string) *sync.Mutex {
+	r.fetchLock.Lock()
+	defer r.fetchLock.Unlock()
+	l, ok := r.fetchLocks[serviceName]
+	if !ok {
+		l = &sync.Mutex{}
+		r.fetchLocks[serviceName] = l
+	}
+	return l
+}
+
+// writeServiceToCache locks per service to alleviate load on consul, some additional lock time
+// is preferable to extra consul calls.  The cache itself is only locked to check and store the result so
+// lookups of other services aren't held up while consul is retried.
+func (r *randomBalancer) writeServiceToCache(serviceName string) (services []*balancer.ServiceLocation, err error) {
+	ctx, span := r.instr.StartSpan(context.Background(), "balancer_write_service_to_cache", instrument.Labels{"service": serviceName})
+	defer func() { span.End(err) }()
+
+	lock := r.serviceLock(serviceName)
+	lock.Lock()
+	defer lock.Unlock()
 
 	//check the cache again in case we've fetched since the last check
 	//(our lock could have been waiting for another call to this function)
-	if result, ok := r.cache[serviceName]; ok {
-		if time.Now().UTC().Before(result.CachedAt.Add(r.ttl)) {
-			return result.Services, nil
-		}
+	r.cacheLock.RLock()
+	result, ok := r.cache[serviceName]
+	r.cacheLock.RUnlock()
+	if ok && time.Now().UTC().Before(result.CachedAt.Add(r.ttl)) {
+		return result.Services, nil
 	}
 
 	//it still isn't in the cache, lets put it there
-	consulServices, _, err := r.consulCatalog.Service(serviceName, r.environment, true, nil)
+	var consulServices []*api.ServiceEntry
+	err = r.retry.Do(ctx, func(attemptCtx context.Context) error {
+		start := time.Now()
+		var err error
+		consulServices, err = r.healthyServices(attemptCtx, serviceName)
+		r.instr.IncCounter("balancer_consul_requests_total", instrument.Labels{"service": serviceName, "result": instrument.Result(err)})
+		r.instr.Observe("balancer_consul_request_seconds", instrument.Since(start), instrument.Labels{"service": serviceName})
+		if err != nil {
+			r.logger.Warn("Could not reach consul", logging.Fields{"service": serviceName, "error": err})
+		}
+		return err
+	})
 	if err != nil {
+		r.logger.Error("Could not look up service", logging.Fields{"service": serviceName, "environment": r.environment, "error": err})
 		return nil, fmt.Errorf("Error reaching consul for service lookup %v", err)
 	}
 
 	if len(consulServices) == 0 {
+		r.logger.Warn("No healthy instances of service", logging.Fields{"service": serviceName, "environment": r.environment})
 		return nil, fmt.Errorf("No services found for %s", serviceName)
 	}
 
 	//setup service locations
-	var services []*balancer.ServiceLocation
 	for _, v := range consulServices {
 		s := &balancer.ServiceLocation{}
 		s.URL = v.Service.Address
@@ -129,6 +213,9 @@ func (r *randomBalancer) writeServiceToCache(serviceName string) ([]*balancer.Se
 
 	// cache
 	c := cachedServiceLocation{Services: services, CachedAt: time.Now().UTC()}
+	r.cacheLock.Lock()
 	r.cache[serviceName] = c
+	r.cacheLock.Unlock()
+	r.logger.Info("Cached service", logging.Fields{"service": serviceName, "environment": r.environment, "instances": len(services)})
 	return services, nil
 }
@@ -1,56 +1,235 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/divideandconquer/go-consul-client/src/config"
+	"github.com/divideandconquer/go-consul-client/src/instrument"
+	"github.com/divideandconquer/go-consul-client/src/logging"
+	"github.com/divideandconquer/go-consul-client/src/retry"
+	"github.com/divideandconquer/go-consul-client/src/secret"
 	"github.com/divideandconquer/go-merge/merge"
 	"github.com/hashicorp/consul/api"
 )
 
 const divider = "/"
 
-type cachedLoader struct {
+const (
+	// watchWaitTime is how long consul may hold a blocking query open before answering with no changes
+	watchWaitTime = 5 * time.Minute
+	// watchRetryDelay is how long Watch backs off after a failed blocking query
+	watchRetryDelay = 5 * time.Second
+)
+
+// layer is the cached contents of a single namespace keyed relative to that namespace
+type layer struct {
 	namespace string
-	cacheLock sync.RWMutex
-	cache     map[string][]byte
-	consulKV  *api.KV
+	pairs     map[string][]byte
+	modified  map[string]uint64 // the consul ModifyIndex of each pair
+	index     uint64
+	loadedAt  time.Time
+	stale     bool // loaded from the snapshot rather than consul
+}
+
+type cachedLoader struct {
+	//counted with sync/atomic and first so they are 64 bit aligned
+	hits   uint64
+	misses uint64
+
+	config.Notifier
+
+	namespaces []string
+	cacheLock  sync.RWMutex
+	layers     []*layer
+	consulKV   *api.KV
+	retry      retry.Policy
+	waitTime   time.Duration
+	keyring    *secret.Keyring
+	schema     *config.Schema
+	instr      instrument.Instrumentation
+	logger     logging.Logger
+
+	snapshotPath string
+	snapshotLock sync.Mutex
+
+	//only used while constructing the loader
+	consulConfig *api.Config
+	consulClient *api.Client
 }
 
 // NewCachedLoader creates a Loader that will cache the provided namespace on initialization
 // and return data from that cache on Get
-func NewCachedLoader(namespace string, consulAddr string) (config.Loader, error) {
-	config := api.DefaultConfig()
-	config.Address = consulAddr
-	consul, err := api.NewClient(config)
-	if err != nil {
-		return nil, fmt.Errorf("Could not connect to consul: %v", err)
+func NewCachedLoader(namespace string, consulAddr string, opts ...Option) (config.Loader, error) {
+	return NewLayeredLoader([]string{namespace}, consulAddr, opts...)
+}
+
+// NewLayeredLoader creates a Loader that will cache every provided namespace on initialization.
+// The namespaces are ordered from most to least specific, e.g. dev/my-app, dev, global, and Get returns
+// the value from the first namespace that has the key.  Import and Put write to the first namespace.
+func NewLayeredLoader(namespaces []string, consulAddr string, opts ...Option) (config.Loader, error) {
+	if len(namespaces) == 0 {
+		return nil, errors.New("At least one namespace is required")
+	}
+
+	c := &cachedLoader{namespaces: namespaces, retry: retry.DefaultPolicy, instr: instrument.Noop, logger: logging.Nop, waitTime: watchWaitTime, consulConfig: api.DefaultConfig()}
+	c.consulConfig.Address = consulAddr
+	for _, opt := range opts {
+		opt(c)
 	}
 
-	return &cachedLoader{namespace: namespace, consulKV: consul.KV()}, nil
+	consul := c.consulClient
+	if consul == nil {
+		var err error
+		consul, err = api.NewClient(c.consulConfig)
+		if err != nil {
+			return nil, fmt.Errorf("Could not connect to consul: %v", err)
+		}
+	}
+	c.consulKV = consul.KV()
+	c.consulConfig, c.consulClient = nil, nil
+
+	//reads are too frequent to report one at a time so they are only counted by instrumentations that read counters
+	if cf, ok := c.instr.(instrument.CounterFuncs); ok {
+		cf.CounterFunc("loader_gets_total", instrument.Labels{"result": "hit"}, func() float64 { return float64(atomic.LoadUint64(&c.hits)) })
+		cf.CounterFunc("loader_gets_total", instrument.Labels{"result": "miss"}, func() float64 { return float64(atomic.LoadUint64(&c.misses)) })
+	}
+
+	for _, ns := range namespaces {
+		c.layers = append(c.layers, &layer{namespace: ns})
+	}
+	return c, nil
 }
 
 // Import takes a json byte array and inserts the key value pairs into consul prefixed by the namespace
 func (c *cachedLoader) Import(data []byte) error {
-	conf := make(map[string]interface{})
-	err := json.Unmarshal(data, &conf)
+	kvMap, err := c.compile(data)
 	if err != nil {
-		return fmt.Errorf("Unable to parse json data: %v", err)
+		return err
+	}
+	if c.schema != nil {
+		current, err := c.listPrimary()
+		if err != nil {
+			return err
+		}
+		if err := c.validateImport(current, kvMap, false); err != nil {
+			return err
+		}
 	}
-	kvMap, err := c.compileKeyValues(conf, c.namespace)
+	return c.putAll(kvMap)
+}
+
+// Sync imports the json like Import and then deletes every key under the namespace that is absent from it.
+// This includes the keys of any other namespace nested beneath this one.  Only keys that differ are written.
+// Sync is refused for a loader without a namespace as it would prune the whole of consul.
+func (c *cachedLoader) Sync(data []byte) error {
+	if c.namespacePrefix() == "" {
+		//pruning outside of a namespace would delete every other key in consul
+		return errors.New("Sync requires a namespace")
+	}
+	plan, err := c.plan(data, true)
 	if err != nil {
-		return fmt.Errorf("Unable to complie KVs: %v", err)
+		return err
+	}
+
+	for _, e := range plan {
+		fullKey := c.qualify(c.namespaces[0], e.Key)
+		if e.Type == config.KeyDeleted {
+			_, err = c.consulKV.Delete(fullKey, nil)
+			if err != nil {
+				return fmt.Errorf("Could not delete key from consul (%s) %v", fullKey, err)
+			}
+			continue
+		}
+		_, err = c.consulKV.Put(&api.KVPair{Key: fullKey, Value: e.New}, nil)
+		if err != nil {
+			return fmt.Errorf("Could not write key to consul (%s | %s) %v", fullKey, e.New, err)
+		}
 	}
+	return nil
+}
+
+// ImportPlan compares the json against the current contents of the namespace in consul without writing anything.
+// Added and modified keys are what Import would write, deleted keys are the ones only Sync would remove.
+func (c *cachedLoader) ImportPlan(data []byte) ([]config.ChangeEvent, error) {
+	return c.plan(data, false)
+}
 
+// plan is ImportPlan validating the result of a Sync rather than an Import when prune is set
+func (c *cachedLoader) plan(data []byte, prune bool) ([]config.ChangeEvent, error) {
+	kvMap, err := c.compile(data)
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.listPrimary()
 	if err != nil {
-		return fmt.Errorf("Could not create consul client: %v", err)
+		return nil, err
 	}
+	if err := c.validateImport(current, kvMap, prune); err != nil {
+		return nil, err
+	}
+
+	events := config.Diff(current, kvMap)
+	for i := range events {
+		events[i].Key, _ = relativeKey(c.namespaces[0], events[i].Key)
+	}
+	return events, nil
+}
+
+// listPrimary lists the first namespace in consul keyed by full consul key
+func (c *cachedLoader) listPrimary() (map[string][]byte, error) {
+	pairs, _, err := c.consulKV.List(c.namespacePrefix(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not pull config from consul: %v", err)
+	}
+	current := make(map[string][]byte, len(pairs))
+	for _, kv := range pairs {
+		current[kv.Key] = kv.Value
+	}
+	return current, nil
+}
+
+// Export reads the first namespace from consul and rebuilds the nested json document it was imported from
+func (c *cachedLoader) Export() ([]byte, error) {
+	pairs, _, err := c.consulKV.List(c.namespacePrefix(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not pull config from consul: %v", err)
+	}
+
+	kvs := make(map[string][]byte, len(pairs))
+	for _, kv := range pairs {
+		if key, ok := relativeKey(c.namespaces[0], kv.Key); ok {
+			kvs[key] = kv.Value
+		}
+	}
+	return config.ExportKeys(kvs)
+}
+
+// compile parses the json and flattens it into consul keys under the first namespace
+func (c *cachedLoader) compile(data []byte) (map[string][]byte, error) {
+	conf := make(map[string]interface{})
+	err := json.Unmarshal(data, &conf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse json data: %v", err)
+	}
+	kvMap, err := c.compileKeyValues(conf, c.namespaces[0])
+	if err != nil {
+		return nil, fmt.Errorf("Unable to complie KVs: %v", err)
+	}
+	return kvMap, nil
+}
+
+func (c *cachedLoader) putAll(kvMap map[string][]byte) error {
 	for k, v := range kvMap {
 		p := &api.KVPair{Key: k, Value: v}
-		_, err = c.consulKV.Put(p, nil)
+		_, err := c.consulKV.Put(p, nil)
 		if err != nil {
 			return fmt.Errorf("Could not write key to consul (%s | %s) %v", k, v, err)
 		}
@@ -58,6 +237,20 @@ func (c *cachedLoader) Import(data []byte) error {
 	return nil
 }
 
+// namespacePrefix is the consul prefix of every key in the first namespace
+func (c *cachedLoader) namespacePrefix() string {
+	return listPrefix(c.namespaces[0])
+}
+
+// listPrefix is the consul prefix of every key in namespace, the trailing divider keeps dev from also
+// listing devops and dev-old
+func listPrefix(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	return namespace + divider
+}
+
 func (c *cachedLoader) compileKeyValues(data map[string]interface{}, prefix string) (map[string][]byte, error) {
 	result := make(map[string][]byte)
 	for k, v := range data {
@@ -92,94 +285,731 @@ func (c *cachedLoader) qualify(prefix, key string) string {
 	return key
 }
 
-// Initialize loads the consul KV's from the namespace into cache for later retrieval
+// Initialize loads the consul KV's from the namespaces into cache for later retrieval
 func (c *cachedLoader) Initialize() error {
-	pairs, _, err := c.consulKV.List(c.namespace, nil)
+	return c.InitializeContext(context.Background())
+}
+
+// InitializeContext is Initialize giving up once ctx is cancelled.  Failed or hung requests to consul are
+// retried using the loader's retry policy.  With WithSnapshot the cache is booted from the snapshot, flagged as stale,
+// when consul still can't be reached.
+func (c *cachedLoader) InitializeContext(ctx context.Context) error {
+	ctx, span := c.instr.StartSpan(ctx, "loader_initialize", instrument.Labels{"namespaces": strings.Join(c.namespaces, ",")})
+	err := c.initialize(ctx)
+	span.End(err)
+	return err
+}
+
+func (c *cachedLoader) initialize(ctx context.Context) error {
+	layers := make([]*layer, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		var pairs api.KVPairs
+		var meta *api.QueryMeta
+		err := c.retry.Do(ctx, func(attemptCtx context.Context) error {
+			start := time.Now()
+			var err error
+			pairs, meta, err = c.list(attemptCtx, ns, nil)
+			c.observeRequest("list", ns, start, err)
+			if err != nil && ctx.Err() == nil {
+				c.logger.Warn("Could not reach consul", logging.Fields{"namespace": ns, "error": err})
+			}
+			return err
+		})
+		if err != nil {
+			err = fmt.Errorf("Could not pull config from consul (%s): %w", ns, err)
+			if c.snapshotPath == "" {
+				c.logger.Error("Could not load config", logging.Fields{"namespace": ns, "error": err})
+				return err
+			}
+			stale, snapErr := c.readSnapshot()
+			if snapErr != nil {
+				err = fmt.Errorf("%w, %v", err, snapErr)
+				c.logger.Error("Could not load config", logging.Fields{"namespace": ns, "snapshot": c.snapshotPath, "error": err})
+				return err
+			}
+			c.swapLayers(stale...)
+			c.logger.Warn("Loaded stale config from snapshot", logging.Fields{
+				"namespaces": strings.Join(c.namespaces, ","),
+				"snapshot":   c.snapshotPath,
+				"age":        c.Status().Age().String(),
+				"error":      err,
+			})
+			return nil
+		}
+		layers = append(layers, c.newLayer(ns, pairs, meta.LastIndex))
+	}
+
+	err := c.validateLayers(layers...)
 	if err != nil {
-		return fmt.Errorf("Could not pull config from consul: %v", err)
+		c.logger.Error("Could not load config", logging.Fields{"namespaces": strings.Join(c.namespaces, ","), "error": err})
+		return err
 	}
+	c.swapLayers(layers...)
+	c.saveSnapshot()
+	for _, l := range layers {
+		c.logger.Info("Loaded config namespace", logging.Fields{"namespace": l.namespace, "keys": len(l.pairs), "index": l.index})
+	}
+	return nil
+}
+
+// Watch long-polls the namespaces with consul blocking queries and swaps the cache whenever a
+// namespace changes.  It blocks until ctx is cancelled so it is usually run in its own goroutine.
+func (c *cachedLoader) Watch(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, ns := range c.namespaces {
+		wg.Add(1)
+		go func(ns string) {
+			defer wg.Done()
+			c.watchNamespace(ctx, ns)
+		}(ns)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (c *cachedLoader) watchNamespace(ctx context.Context, namespace string) {
+	for {
+		index, stale := c.layerState(namespace)
+		waitIndex := index
+		if stale {
+			//a layer booted from the snapshot is refreshed as soon as consul answers rather than on the next change
+			waitIndex = 0
+		}
+		start := time.Now()
+		pairs, meta, err := c.blockingList(ctx, namespace, waitIndex)
+		if ctx.Err() != nil {
+			return
+		}
+		c.observeRequest("watch", namespace, start, err)
+		if err != nil {
+			c.logger.Warn("Could not watch config namespace", logging.Fields{"namespace": namespace, "error": err})
+			//consul is unreachable, back off before polling again
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetryDelay):
+			}
+			continue
+		}
+
+		//the wait time elapsed without any changes to the namespace
+		if meta.LastIndex == index && !stale {
+			continue
+		}
+		updated := c.newLayer(namespace, pairs, meta.LastIndex)
+		if err := c.validateLayers(updated); err != nil {
+			//keep serving the last valid config until the namespace is fixed
+			c.logger.Error("Ignoring change to config namespace", logging.Fields{"namespace": namespace, "index": meta.LastIndex, "error": err})
+			c.instr.IncCounter("loader_reloads_total", instrument.Labels{"namespace": namespace, "result": "rejected"})
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetryDelay):
+			}
+			continue
+		}
+		c.swapLayers(updated)
+		c.saveSnapshot()
+		c.instr.IncCounter("loader_reloads_total", instrument.Labels{"namespace": namespace, "result": "success"})
+		c.logger.Info("Reloaded config namespace", logging.Fields{"namespace": namespace, "keys": len(updated.pairs), "index": updated.index})
+	}
+}
+
+// observeRequest records the outcome and latency of a request to consul
+func (c *cachedLoader) observeRequest(op, namespace string, start time.Time, err error) {
+	c.instr.IncCounter("loader_consul_requests_total", instrument.Labels{"op": op, "namespace": namespace, "result": instrument.Result(err)})
+	c.instr.Observe("loader_consul_request_seconds", instrument.Since(start), instrument.Labels{"op": op, "namespace": namespace})
+}
+
+// blockingList lists the namespace once the consul index moves past index, returning early if ctx is cancelled
+func (c *cachedLoader) blockingList(ctx context.Context, namespace string, index uint64) (api.KVPairs, *api.QueryMeta, error) {
+	return c.list(ctx, namespace, &api.QueryOptions{WaitIndex: index, WaitTime: c.waitTime})
+}
 
+// list lists the namespace returning early if ctx is cancelled
+func (c *cachedLoader) list(ctx context.Context, namespace string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error) {
+	var pairs api.KVPairs
+	var meta *api.QueryMeta
+	err := retry.Call(ctx, func() error {
+		var err error
+		pairs, meta, err = c.consulKV.List(listPrefix(namespace), q)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pairs, meta, nil
+}
+
+// newLayer builds the cache of a namespace from the pairs listed from consul.  Keys that belong to another
+// namespace nested inside this one (dev/my-app inside dev) are left to that namespace's layer.
+func (c *cachedLoader) newLayer(namespace string, pairs api.KVPairs, index uint64) *layer {
+	l := &layer{
+		namespace: namespace,
+		pairs:     make(map[string][]byte, len(pairs)),
+		modified:  make(map[string]uint64, len(pairs)),
+		index:     index,
+		loadedAt:  time.Now(),
+	}
+	for _, kv := range pairs {
+		key, ok := relativeKey(namespace, kv.Key)
+		if !ok || c.ownedByNestedNamespace(namespace, kv.Key) {
+			continue
+		}
+		l.pairs[key] = kv.Value
+		l.modified[key] = kv.ModifyIndex
+	}
+	return l
+}
+
+func (c *cachedLoader) ownedByNestedNamespace(namespace, key string) bool {
+	for _, ns := range c.namespaces {
+		if ns == namespace {
+			continue
+		}
+		if _, nested := relativeKey(namespace, ns); !nested {
+			continue
+		}
+		if _, owned := relativeKey(ns, key); owned {
+			return true
+		}
+	}
+	return false
+}
+
+// relativeKey strips the namespace from a consul key so it matches what is passed to Get
+func relativeKey(namespace, key string) (string, bool) {
+	if namespace == "" {
+		return key, true
+	}
+	if !strings.HasPrefix(key, namespace+divider) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, namespace+divider), true
+}
+
+// swapLayers replaces the cached layers for the namespaces of the given layers in a single step
+func (c *cachedLoader) swapLayers(updated ...*layer) {
 	//write lock the cache incase init is called more than once
 	c.cacheLock.Lock()
-	defer c.cacheLock.Unlock()
+	before := c.effective()
+	for _, u := range updated {
+		for i, l := range c.layers {
+			if l.namespace == u.namespace {
+				c.layers[i] = u
+			}
+		}
+	}
+	events := config.Diff(before, c.effective())
+	c.cacheLock.Unlock()
 
-	c.cache = make(map[string][]byte)
-	for _, kv := range pairs {
-		c.cache[kv.Key] = kv.Value
+	//subscribers must never run under the cache lock
+	c.Notify(c.decryptEvents(events))
+}
+
+// Status reports when the least recently loaded namespace was loaded and whether any namespace came from the snapshot
+func (c *cachedLoader) Status() config.Status {
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+
+	var s config.Status
+	for _, l := range c.layers {
+		if l.loadedAt.IsZero() {
+			//not loaded yet
+			return config.Status{}
+		}
+		if s.LoadedAt.IsZero() || l.loadedAt.Before(s.LoadedAt) {
+			s.LoadedAt = l.loadedAt
+		}
+		s.Stale = s.Stale || l.stale
 	}
-	return nil
+	return s
 }
 
-// Get fetches the raw config from cache
+// layerState returns the consul index of the namespace's layer and whether it came from the snapshot
+func (c *cachedLoader) layerState(namespace string) (uint64, bool) {
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+	for _, l := range c.layers {
+		if l.namespace == namespace {
+			return l.index, l.stale
+		}
+	}
+	return 0, false
+}
+
+// effective merges the layers so more specific namespaces win, callers must hold the cache lock
+func (c *cachedLoader) effective() map[string][]byte {
+	result := make(map[string][]byte)
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		for k, v := range c.layers[i].pairs {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// Get fetches the raw config from cache, encrypted values are decrypted with the loader's keyring and
+// references to other keys in string values are resolved, see config.Interpolate
 func (c *cachedLoader) Get(key string) ([]byte, error) {
+	c.cacheLock.RLock()
+	ret, err := c.lookupLocked(key)
+	if err != nil {
+		c.cacheLock.RUnlock()
+		atomic.AddUint64(&c.misses, 1)
+		return nil, err
+	}
+	ret, err = config.Interpolate(key, ret, c.lookupLocked)
+	c.cacheLock.RUnlock()
+
+	atomic.AddUint64(&c.hits, 1)
+	return ret, err
+}
+
+// GetRaw is Get without resolving references to other keys, see config.RawGetter
+func (c *cachedLoader) GetRaw(key string) ([]byte, error) {
 	c.cacheLock.RLock()
 	defer c.cacheLock.RUnlock()
+	return c.lookupLocked(key)
+}
 
-	compiledKey := c.namespace + divider + key
-	if ret, ok := c.cache[compiledKey]; ok {
-		return ret, nil
+// lookupLocked is Get without interpolation for callers that already hold the cache lock
+func (c *cachedLoader) lookupLocked(key string) ([]byte, error) {
+	ret, _, err := c.resolveLocked(key)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("Could not find value for key: %s", compiledKey)
+	return c.decrypt(key, ret)
 }
 
-// MustGetString fetches the config and parses it into a string.  Panics on failure.
-func (c *cachedLoader) MustGetString(key string) string {
+// decrypt opens value if it was encrypted and returns it unchanged otherwise
+func (c *cachedLoader) decrypt(key string, value []byte) ([]byte, error) {
+	ret, err := c.keyring.Decrypt(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt config (%s) %v", key, err)
+	}
+	return ret, nil
+}
+
+// decryptEvents decrypts the values of the events and drops modifications that only re-encrypted the same value.
+// Values that can't be decrypted are delivered as they are.
+func (c *cachedLoader) decryptEvents(events []config.ChangeEvent) []config.ChangeEvent {
+	result := events[:0]
+	for _, e := range events {
+		if old, err := c.keyring.Decrypt(e.Key, e.Old); err == nil {
+			e.Old = old
+		}
+		if new, err := c.keyring.Decrypt(e.Key, e.New); err == nil {
+			e.New = new
+		}
+		if e.Type == config.KeyModified && bytes.Equal(e.Old, e.New) {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// Source reports which namespace the value for key is read from
+func (c *cachedLoader) Source(key string) (string, error) {
+	_, namespace, err := c.resolve(key)
+	return namespace, err
+}
+
+// resolve finds key in the most specific namespace that has it
+func (c *cachedLoader) resolve(key string) ([]byte, string, error) {
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+	return c.resolveLocked(key)
+}
+
+// resolveLocked is resolve for callers that already hold the cache lock
+func (c *cachedLoader) resolveLocked(key string) ([]byte, string, error) {
+	for _, l := range c.layers {
+		if ret, ok := l.pairs[key]; ok {
+			return ret, l.namespace, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s in %s", config.ErrNotFound, key, strings.Join(c.namespaces, ", "))
+}
+
+// Sub returns a loader rooted at namespace/prefix that reads from this loader's cache
+func (c *cachedLoader) Sub(prefix string) config.Loader {
+	return config.Scope(c, prefix)
+}
+
+// Unmarshal decodes the cached keys beneath prefix into v
+func (c *cachedLoader) Unmarshal(prefix string, v interface{}) error {
+	kvs, err := c.scopedKeys(prefix)
+	if err != nil {
+		return err
+	}
+	return config.UnmarshalKeys(kvs, prefix, v)
+}
+
+// scopedKeys copies the effective keys at and beneath prefix decrypting and interpolating their values
+func (c *cachedLoader) scopedKeys(prefix string) (map[string][]byte, error) {
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+
+	prefix = strings.Trim(prefix, divider)
+	result := make(map[string][]byte)
+	for k, v := range c.effective() {
+		//ancestors are kept as they may hold the prefix inside a json object
+		if prefix == "" || k == prefix || strings.HasPrefix(k, prefix+divider) || strings.HasPrefix(prefix, k+divider) {
+			plain, err := c.decrypt(k, v)
+			if err != nil {
+				return nil, err
+			}
+			result[k], err = config.Interpolate(k, plain, c.lookupLocked)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// GetString fetches the config and parses it into a string
+func (c *cachedLoader) GetString(key string) (string, error) {
 	b, err := c.Get(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not fetch config (%s) %v", key, err))
+		return "", fmt.Errorf("Could not fetch config (%s) %w", key, err)
 	}
 
 	var s string
 	err = json.Unmarshal(b, &s)
 	if err != nil {
-		panic(fmt.Sprintf("Could not unmarshal config (%s) %v", key, err))
+		return "", fmt.Errorf("Could not unmarshal config (%s) %v", key, err)
 	}
-
-	return s
+	return s, nil
 }
 
-// MustGetBool fetches the config and parses it into a bool.  Panics on failure.
-func (c *cachedLoader) MustGetBool(key string) bool {
+// GetBool fetches the config and parses it into a bool
+func (c *cachedLoader) GetBool(key string) (bool, error) {
 	b, err := c.Get(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not fetch config (%s) %v", key, err))
+		return false, fmt.Errorf("Could not fetch config (%s) %w", key, err)
 	}
+
 	var ret bool
 	err = json.Unmarshal(b, &ret)
 	if err != nil {
-		panic(fmt.Sprintf("Could not unmarshal config (%s) %v", key, err))
+		return false, fmt.Errorf("Could not unmarshal config (%s) %v", key, err)
 	}
-	return ret
+	return ret, nil
 }
 
-// MustGetInt fetches the config and parses it into an int.  Panics on failure.
-func (c *cachedLoader) MustGetInt(key string) int {
+// GetInt fetches the config and parses it into an int
+func (c *cachedLoader) GetInt(key string) (int, error) {
 	b, err := c.Get(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not fetch config (%s) %v", key, err))
+		return 0, fmt.Errorf("Could not fetch config (%s) %w", key, err)
 	}
 
 	var ret int
 	err = json.Unmarshal(b, &ret)
 	if err != nil {
-		panic(fmt.Sprintf("Could not unmarshal config (%s) %v", key, err))
+		return 0, fmt.Errorf("Could not unmarshal config (%s) %v", key, err)
+	}
+	return ret, nil
+}
+
+// GetDuration fetches the config and parses it into a duration
+func (c *cachedLoader) GetDuration(key string) (time.Duration, error) {
+	s, err := c.GetString(key)
+	if err != nil {
+		return 0, err
+	}
+
+	ret, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("Could not parse config (%s) into a duration: %v", key, err)
+	}
+	return ret, nil
+}
+
+// GetStringSlice fetches the config and parses it into a slice of strings
+func (c *cachedLoader) GetStringSlice(key string) ([]string, error) {
+	var ret []string
+	kvs, err := c.scopedKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	err = config.DecodeKey(kvs, key, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetIntSlice fetches the config and parses it into a slice of ints
+func (c *cachedLoader) GetIntSlice(key string) ([]int, error) {
+	var ret []int
+	kvs, err := c.scopedKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	err = config.DecodeKey(kvs, key, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetStringMap fetches the config and parses it into a map of strings
+func (c *cachedLoader) GetStringMap(key string) (map[string]string, error) {
+	var ret map[string]string
+	kvs, err := c.scopedKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	err = config.DecodeKey(kvs, key, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetStringOr fetches the config and parses it into a string.  Returns def on failure.
+func (c *cachedLoader) GetStringOr(key string, def string) string {
+	s, err := c.GetString(key)
+	if err != nil {
+		return def
+	}
+	return s
+}
+
+// GetBoolOr fetches the config and parses it into a bool.  Returns def on failure.
+func (c *cachedLoader) GetBoolOr(key string, def bool) bool {
+	ret, err := c.GetBool(key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+// GetIntOr fetches the config and parses it into an int.  Returns def on failure.
+func (c *cachedLoader) GetIntOr(key string, def int) int {
+	ret, err := c.GetInt(key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+// GetDurationOr fetches the config and parses it into a duration.  Returns def on failure.
+func (c *cachedLoader) GetDurationOr(key string, def time.Duration) time.Duration {
+	ret, err := c.GetDuration(key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+// MustGetString fetches the config and parses it into a string.  Panics on failure.
+func (c *cachedLoader) MustGetString(key string) string {
+	s, err := c.GetString(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return s
+}
+
+// MustGetBool fetches the config and parses it into a bool.  Panics on failure.
+func (c *cachedLoader) MustGetBool(key string) bool {
+	ret, err := c.GetBool(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// MustGetInt fetches the config and parses it into an int.  Panics on failure.
+func (c *cachedLoader) MustGetInt(key string) int {
+	ret, err := c.GetInt(key)
+	if err != nil {
+		panic(err.Error())
 	}
 	return ret
 }
 
 // MustGetDuration fetches the config and parses it into a duration.  Panics on failure.
 func (c *cachedLoader) MustGetDuration(key string) time.Duration {
-	s := c.MustGetString(key)
-	ret, err := time.ParseDuration(s)
+	ret, err := c.GetDuration(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not parse config (%s) into a duration: %v", key, err))
+		panic(err.Error())
 	}
 	return ret
 }
 
+// MustGetStringSlice fetches the config and parses it into a slice of strings.  Panics on failure.
+func (c *cachedLoader) MustGetStringSlice(key string) []string {
+	ret, err := c.GetStringSlice(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// MustGetIntSlice fetches the config and parses it into a slice of ints.  Panics on failure.
+func (c *cachedLoader) MustGetIntSlice(key string) []int {
+	ret, err := c.GetIntSlice(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// MustGetStringMap fetches the config and parses it into a map of strings.  Panics on failure.
+func (c *cachedLoader) MustGetStringMap(key string) map[string]string {
+	ret, err := c.GetStringMap(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// Put writes the value to consul under the first namespace and updates the cache once consul accepts it.
+// The value is encrypted with the keyring when the key's current value is encrypted, see WithKeyring.
 func (c *cachedLoader) Put(key string, value []byte) error {
-	c.cacheLock.Lock()
-	defer c.cacheLock.Unlock()
-	c.cache[key] = value
+	return c.put(key, value, false)
+}
+
+// PutCAS is Put using check-and-set against the ModifyIndex the key had when it was last read, so a write
+// made by someone else since then is not clobbered.  Keys that have never been read are only created if
+// they don't exist yet.  Returns an error wrapping config.ErrConflict when the check fails.
+func (c *cachedLoader) PutCAS(key string, value []byte) error {
+	return c.put(key, value, true)
+}
+
+func (c *cachedLoader) put(key string, value []byte, cas bool) error {
+	key = strings.Trim(key, divider)
+	fullKey := c.qualify(c.namespaces[0], key)
+
+	value, err := c.seal(key, value)
+	if err != nil {
+		return err
+	}
+	p := &api.KVPair{Key: fullKey, Value: value}
+
+	err = c.validatePut(key, value)
+	if err != nil {
+		return err
+	}
+
+	if cas {
+		c.cacheLock.RLock()
+		p.ModifyIndex = c.layers[0].modified[key]
+		c.cacheLock.RUnlock()
+
+		ok, _, err := c.consulKV.CAS(p, nil)
+		if err != nil {
+			return fmt.Errorf("Could not write key to consul (%s | %s) %v", fullKey, value, err)
+		}
+		if !ok {
+			return fmt.Errorf("Could not write key to consul (%s) %w", fullKey, config.ErrConflict)
+		}
+	} else {
+		_, err := c.consulKV.Put(p, nil)
+		if err != nil {
+			return fmt.Errorf("Could not write key to consul (%s | %s) %v", fullKey, value, err)
+		}
+	}
+
+	//read the key back for its new ModifyIndex so the next check-and-set is made against this write,
+	//if that fails the next check-and-set conflicts until the cache is reloaded
+	var modified uint64
+	if pair, _, err := c.consulKV.Get(fullKey, nil); err == nil && pair != nil {
+		modified = pair.ModifyIndex
+	}
+
+	c.updatePrimary(func(l *layer) {
+		l.pairs[key] = value
+		l.modified[key] = modified
+	})
 	return nil
 }
+
+// seal encrypts value with the loader's keyring when the value Get currently reads for key is encrypted, so a
+// Put doesn't quietly store a secret in plaintext.  Values that are already encrypted are written as they are.
+func (c *cachedLoader) seal(key string, value []byte) ([]byte, error) {
+	current, _, err := c.resolve(key)
+	if err != nil || !secret.IsEncrypted(current) || secret.IsEncrypted(value) {
+		return value, nil
+	}
+	if c.keyring == nil {
+		return nil, fmt.Errorf("Could not encrypt config (%s) %w", key, secret.ErrNoKeyring)
+	}
+	sealed, err := c.keyring.Encrypt(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("Could not encrypt config (%s) %v", key, err)
+	}
+	return sealed, nil
+}
+
+// Delete removes the key from consul under the first namespace and then from the cache.
+// Get falls back to the other namespaces once the key is deleted.
+func (c *cachedLoader) Delete(key string) error {
+	key = strings.Trim(key, divider)
+	fullKey := c.qualify(c.namespaces[0], key)
+	_, err := c.consulKV.Delete(fullKey, nil)
+	if err != nil {
+		return fmt.Errorf("Could not delete key from consul (%s) %v", fullKey, err)
+	}
+
+	c.updatePrimary(func(l *layer) {
+		delete(l.pairs, key)
+		delete(l.modified, key)
+	})
+	return nil
+}
+
+// DeleteTree removes prefix and every key beneath it from consul under the first namespace and then from the cache
+func (c *cachedLoader) DeleteTree(prefix string) error {
+	prefix = strings.Trim(prefix, divider)
+	fullPrefix := c.namespacePrefix()
+	if prefix == "" && fullPrefix == "" {
+		return errors.New("DeleteTree requires a namespace or a prefix, refusing to delete every key in consul")
+	}
+	if prefix != "" {
+		fullPrefix = c.qualify(c.namespaces[0], prefix)
+		//delete the prefix itself separately so siblings sharing its name (a and ab) are left alone
+		_, err := c.consulKV.Delete(fullPrefix, nil)
+		if err != nil {
+			return fmt.Errorf("Could not delete key from consul (%s) %v", fullPrefix, err)
+		}
+		fullPrefix += divider
+	}
+	_, err := c.consulKV.DeleteTree(fullPrefix, nil)
+	if err != nil {
+		return fmt.Errorf("Could not delete tree from consul (%s) %v", fullPrefix, err)
+	}
+
+	c.updatePrimary(func(l *layer) {
+		for k := range l.pairs {
+			if prefix == "" || k == prefix || strings.HasPrefix(k, prefix+divider) {
+				delete(l.pairs, k)
+				delete(l.modified, k)
+			}
+		}
+	})
+	return nil
+}
+
+// updatePrimary applies a change made in consul to the cached layer of the first namespace
+func (c *cachedLoader) updatePrimary(update func(l *layer)) {
+	c.cacheLock.Lock()
+	before := c.effective()
+	l := c.layers[0]
+	if l.pairs == nil {
+		l.pairs = make(map[string][]byte)
+		l.modified = make(map[string]uint64)
+	}
+	update(l)
+	events := config.Diff(before, c.effective())
+	c.cacheLock.Unlock()
+
+	//subscribers must never run under the cache lock
+	c.Notify(c.decryptEvents(events))
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/divideandconquer/go-consul-client/src/config"
+	"github.com/divideandconquer/go-consul-client/src/secret"
+)
+
+func TestPutEncryptedKey(t *testing.T) {
+	keyring, err := secret.NewKeyring("k", map[string][]byte{"k": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	sealed, err := keyring.Encrypt("database/password", []byte(`"old"`))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keyring *secret.Keyring
+		key     string
+		cas     bool
+		value   string
+		wantEnc bool
+		wantErr error
+	}{
+		{name: "encrypted key is sealed", keyring: keyring, key: "database/password", value: `"new"`, wantEnc: true},
+		{name: "encrypted key is sealed with cas", keyring: keyring, key: "database/password", cas: true, value: `"new"`, wantEnc: true},
+		{name: "plain key stays plain", keyring: keyring, key: "database/host", value: `"db"`},
+		{name: "new key stays plain", keyring: keyring, key: "database/user", value: `"app"`},
+		{name: "encrypted key without a keyring", key: "database/password", value: `"new"`, wantErr: secret.ErrNoKeyring},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.keyring != nil {
+				opts = append(opts, WithKeyring(tt.keyring))
+			}
+			c, f := newTestLoader(t, []string{"app"}, map[string]string{
+				"app/database/password": string(sealed),
+				"app/database/host":     `"localhost"`,
+			}, opts...)
+			if err := c.Initialize(); err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+
+			put := c.Put
+			if tt.cas {
+				put = c.PutCAS
+			}
+			err := put(tt.key, []byte(tt.value))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Put error = %v, want %v", err, tt.wantErr)
+				}
+				if stored, _ := f.get("app/" + tt.key); stored != string(sealed) {
+					t.Fatalf("consul holds %s after a failed Put", stored)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			stored, _ := f.get("app/" + tt.key)
+			if secret.IsEncrypted([]byte(stored)) != tt.wantEnc || strings.Contains(stored, strings.Trim(tt.value, `"`)) == tt.wantEnc {
+				t.Fatalf("consul holds %s, want encrypted: %v", stored, tt.wantEnc)
+			}
+			if got := mustGetString(t, c, tt.key); `"`+got+`"` != tt.value {
+				t.Fatalf("GetString = %s, want %s", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestWatchReloads(t *testing.T) {
+	c, f := newTestLoader(t, []string{"app", "base"}, map[string]string{
+		"app/host":  `"a"`,
+		"base/host": `"base"`,
+		"base/port": `"1"`,
+	})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	events, unsubscribe := c.Subscribe("")
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Watch(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	tests := []struct {
+		name   string
+		change func()
+		want   config.ChangeEvent
+		key    string
+		value  string
+	}{
+		{name: "modified", change: func() { f.set("app/host", `"b"`) }, want: config.ChangeEvent{Key: "host", Type: config.KeyModified, Old: []byte(`"a"`), New: []byte(`"b"`)}, key: "host", value: "b"},
+		{name: "added", change: func() { f.set("app/name", `"n"`) }, want: config.ChangeEvent{Key: "name", Type: config.KeyAdded, New: []byte(`"n"`)}, key: "name", value: "n"},
+		{name: "modified in a lower namespace", change: func() { f.set("base/port", `"2"`) }, want: config.ChangeEvent{Key: "port", Type: config.KeyModified, Old: []byte(`"1"`), New: []byte(`"2"`)}, key: "port", value: "2"},
+		{name: "deleted falls back to a lower namespace", change: func() { f.remove("app/host") }, want: config.ChangeEvent{Key: "host", Type: config.KeyModified, Old: []byte(`"b"`), New: []byte(`"base"`)}, key: "host", value: "base"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			select {
+			case e := <-events:
+				if !reflect.DeepEqual(e, tt.want) {
+					t.Fatalf("event = %+v, want %+v", e, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for the change event")
+			}
+			if got := mustGetString(t, c, tt.key); got != tt.value {
+				t.Fatalf("GetString(%s) = %s, want %s", tt.key, got, tt.value)
+			}
+		})
+	}
+}
+
+func TestPutCAS(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		changedAfter bool // someone else writes the key after the loader read it
+		twice        bool
+		wantConflict bool
+	}{
+		{name: "unchanged since read", key: "host"},
+		{name: "changed since read", key: "host", changedAfter: true, wantConflict: true},
+		{name: "new key", key: "name"},
+		{name: "new key created by someone else", key: "name", changedAfter: true, wantConflict: true},
+		{name: "consecutive writes", key: "host", twice: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, f := newTestLoader(t, []string{"app"}, map[string]string{"app/host": `"a"`})
+			if err := c.Initialize(); err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			if tt.changedAfter {
+				f.set("app/"+tt.key, `"theirs"`)
+			}
+
+			err := c.PutCAS(tt.key, []byte(`"mine"`))
+			if tt.twice && err == nil {
+				err = c.PutCAS(tt.key, []byte(`"mine again"`))
+			}
+			if tt.wantConflict {
+				if !errors.Is(err, config.ErrConflict) {
+					t.Fatalf("PutCAS error = %v, want ErrConflict", err)
+				}
+				if stored, _ := f.get("app/" + tt.key); stored != `"theirs"` {
+					t.Fatalf("consul holds %s, want the other write kept", stored)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PutCAS: %v", err)
+			}
+			want := `"mine"`
+			if tt.twice {
+				want = `"mine again"`
+			}
+			if stored, _ := f.get("app/" + tt.key); stored != want {
+				t.Fatalf("consul holds %s, want %s", stored, want)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	c, f := newTestLoader(t, []string{"app"}, map[string]string{
+		"app/host":          `"a"`,
+		"app/port":          `1`,
+		"app/tls/ca":        `"ca.pem"`,
+		"app/tls/cert":      `"cert.pem"`,
+		"apps/host":         `"sibling"`,
+		"other/host":        `"other"`,
+		"app/nested/secret": `"s"`,
+	})
+	modified := f.pairs["app/port"].modify
+	if err := c.Sync([]byte(`{"host": "b", "port": 1, "tls": {"ca": "ca.pem"}}`)); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	want := []string{"app/host", "app/port", "app/tls/ca", "apps/host", "other/host"}
+	if got := f.keys(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("consul holds %v, want %v", got, want)
+	}
+	if stored, _ := f.get("app/host"); stored != `"b"` {
+		t.Fatalf("app/host = %s, want \"b\"", stored)
+	}
+	if f.pairs["app/port"].modify != modified {
+		t.Fatal("Sync rewrote app/port although it was unchanged")
+	}
+}
+
+func TestRootNamespaceRefusesPruning(t *testing.T) {
+	pairs := map[string]string{"app/host": `"a"`, "other/host": `"b"`}
+	tests := []struct {
+		name    string
+		prune   func(c *cachedLoader) error
+		wantErr string
+	}{
+		{name: "sync", prune: func(c *cachedLoader) error { return c.Sync([]byte(`{}`)) }, wantErr: "Sync requires a namespace"},
+		{name: "delete tree", prune: func(c *cachedLoader) error { return c.DeleteTree("/") }, wantErr: "DeleteTree requires a namespace or a prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, f := newTestLoader(t, []string{""}, pairs)
+			err := tt.prune(c)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if got := f.keys(); len(got) != len(pairs) {
+				t.Fatalf("consul holds %v after a refused prune", got)
+			}
+		})
+	}
+}
+
+func TestDeleteTree(t *testing.T) {
+	c, f := newTestLoader(t, []string{"app"}, map[string]string{
+		"app/tls":     `{"ca":"ca.pem"}`,
+		"app/tls/ca":  `"ca.pem"`,
+		"app/tlsx":    `true`,
+		"app/host":    `"a"`,
+		"other/tls/x": `1`,
+	})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := c.DeleteTree("tls"); err != nil {
+		t.Fatalf("DeleteTree: %v", err)
+	}
+
+	want := []string{"app/host", "app/tlsx", "other/tls/x"}
+	if got := f.keys(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("consul holds %v, want %v", got, want)
+	}
+	if _, err := c.Get("tls/ca"); !errors.Is(err, config.ErrNotFound) {
+		t.Fatalf("Get(tls/ca) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNamespaceSiblings(t *testing.T) {
+	c, f := newTestLoader(t, []string{"dev"}, map[string]string{"dev/host": `"a"`, "devops/host": `"b"`, "devops/port": `1`})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if got := mustGetString(t, c, "host"); got != "a" {
+		t.Fatalf("GetString(host) = %s, want a", got)
+	}
+	if _, err := c.Get("port"); !errors.Is(err, config.ErrNotFound) {
+		t.Fatalf("Get(port) error = %v, want ErrNotFound", err)
+	}
+	for _, prefix := range f.listed {
+		if prefix != "dev/" {
+			t.Fatalf("listed %q, want only dev/", prefix)
+		}
+	}
+}
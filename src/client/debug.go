@@ -0,0 +1,179 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/divideandconquer/go-consul-client/src/config"
+	"github.com/divideandconquer/go-consul-client/src/debugview"
+	"github.com/divideandconquer/go-consul-client/src/secret"
+)
+
+// DefaultRedactPatterns are the key fragments NewDebugHandler hides the values of, matched ignoring case
+var DefaultRedactPatterns = []string{"password", "passwd", "secret", "token", "credential", "private", "apikey", "api_key"}
+
+const (
+	redacted  = "[redacted]"
+	encrypted = "[encrypted]"
+)
+
+type debugHandler struct {
+	loader *cachedLoader
+	redact []string
+}
+
+// debugLayer describes a cached namespace
+type debugLayer struct {
+	Namespace string    `json:"namespace"`
+	Index     uint64    `json:"index"`
+	LoadedAt  time.Time `json:"loaded_at"`
+	Stale     bool      `json:"stale"`
+	Keys      int       `json:"keys"`
+}
+
+// debugKey describes the value Get returns for a key
+type debugKey struct {
+	Key         string      `json:"key"`
+	Value       interface{} `json:"value"`
+	Namespace   string      `json:"namespace"`
+	ModifyIndex uint64      `json:"modify_index"`
+	Redacted    bool        `json:"redacted,omitempty"`
+}
+
+type debugConfig struct {
+	LoadedAt time.Time    `json:"loaded_at"`
+	Stale    bool         `json:"stale"`
+	Layers   []debugLayer `json:"layers"`
+	Keys     []debugKey   `json:"keys"`
+}
+
+// NewDebugHandler returns an http.Handler that renders what a loader created by NewCachedLoader or
+// NewLayeredLoader has cached: every namespace with its consul index and load time and every key with its
+// value and the namespace it is read from.  Responses are json unless html is asked for with ?format=html or
+// an Accept header.  Encrypted values are never shown and neither are the values of keys containing any of
+// the redact patterns, DefaultRedactPatterns when none are given.  References to other keys are shown as
+// stored so the values they point at stay hidden.
+func NewDebugHandler(l config.Loader, redact ...string) (http.Handler, error) {
+	c, ok := l.(*cachedLoader)
+	if !ok {
+		return nil, errors.New("Debug handler requires a loader created by NewCachedLoader or NewLayeredLoader")
+	}
+	if len(redact) == 0 {
+		redact = DefaultRedactPatterns
+	}
+	patterns := make([]string, len(redact))
+	for i, p := range redact {
+		patterns[i] = strings.ToLower(p)
+	}
+	return &debugHandler{loader: c, redact: patterns}, nil
+}
+
+func (d *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	debugview.Render(w, r, debugConfigTemplate, d.snapshot())
+}
+
+func (d *debugHandler) snapshot() debugConfig {
+	status := d.loader.Status()
+	view := debugConfig{LoadedAt: status.LoadedAt, Stale: status.Stale}
+
+	d.loader.cacheLock.RLock()
+	defer d.loader.cacheLock.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, l := range d.loader.layers {
+		view.Layers = append(view.Layers, debugLayer{Namespace: l.namespace, Index: l.index, LoadedAt: l.loadedAt, Stale: l.stale, Keys: len(l.pairs)})
+
+		//layers are ordered from most specific so the first one with a key is the one Get reads
+		for k, v := range l.pairs {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			entry := debugKey{Key: k, Namespace: l.namespace, ModifyIndex: l.modified[k]}
+			switch {
+			case secret.IsEncrypted(v):
+				entry.Value, entry.Redacted = encrypted, true
+			case d.redacted(k):
+				entry.Value, entry.Redacted = redacted, true
+			default:
+				//secrets may also be nested inside a json value such as {"creds": [{"password": "p"}]}
+				entry.Value = d.redactNested(decodeDebugValue(v))
+			}
+			view.Keys = append(view.Keys, entry)
+		}
+	}
+	sort.Slice(view.Keys, func(i, j int) bool { return view.Keys[i].Key < view.Keys[j].Key })
+	return view
+}
+
+func (d *debugHandler) redacted(key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range d.redact {
+		if strings.Contains(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactNested replaces the values of object keys containing any of the redact patterns at any depth of v
+func (d *debugHandler) redactNested(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range t {
+			if d.redacted(k) {
+				t[k] = redacted
+				continue
+			}
+			t[k] = d.redactNested(sub)
+		}
+	case []interface{}:
+		for i, sub := range t {
+			t[i] = d.redactNested(sub)
+		}
+	}
+	return v
+}
+
+// decodeDebugValue decodes a stored json value, values that aren't json are shown as strings
+func decodeDebugValue(v []byte) interface{} {
+	var ret interface{}
+	if err := json.Unmarshal(v, &ret); err != nil {
+		return string(v)
+	}
+	return ret
+}
+
+var debugConfigTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"json": func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	},
+	"age": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return time.Since(t).Truncate(time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><title>Config</title></head>
+<body>
+<h1>Config</h1>
+<p>Loaded {{age .LoadedAt}} ago{{if .Stale}}, stale{{end}}</p>
+<table border="1">
+<tr><th>Namespace</th><th>Index</th><th>Loaded</th><th>Stale</th><th>Keys</th></tr>
+{{range .Layers}}<tr><td>{{.Namespace}}</td><td>{{.Index}}</td><td>{{.LoadedAt.Format "2006-01-02T15:04:05Z07:00"}} ({{age .LoadedAt}} ago)</td><td>{{.Stale}}</td><td>{{.Keys}}</td></tr>
+{{end}}</table>
+<table border="1">
+<tr><th>Key</th><th>Value</th><th>Namespace</th><th>Modify index</th></tr>
+{{range .Keys}}<tr><td>{{.Key}}</td><td>{{if .Redacted}}<i>{{.Value}}</i>{{else}}<code>{{json .Value}}</code>{{end}}</td><td>{{.Namespace}}</td><td>{{.ModifyIndex}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
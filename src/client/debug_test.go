@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDebugHandlerRedacts(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  interface{}
+	}{
+		{name: "plain value", key: "database/host", value: `"db"`, want: "db"},
+		{name: "key matches", key: "database/password", value: `"p"`, want: redacted},
+		{name: "key matches ignoring case", key: "api/Token", value: `"t"`, want: redacted},
+		{name: "encrypted", key: "database/host", value: `"secret:v1:k:AAAA"`, want: encrypted},
+		{name: "nested object key", key: "database", value: `{"host":"db","password":"p"}`, want: map[string]interface{}{"host": "db", "password": redacted}},
+		{name: "nested in an array", key: "creds", value: `[{"user":"u","password":"p"}]`, want: []interface{}{map[string]interface{}{"user": "u", "password": redacted}}},
+		{name: "nested object beneath a secret key", key: "auth", value: `{"secret":{"a":"b"}}`, want: map[string]interface{}{"secret": redacted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &cachedLoader{namespaces: []string{"app"}, layers: []*layer{{
+				namespace: "app",
+				pairs:     map[string][]byte{tt.key: []byte(tt.value)},
+				modified:  map[string]uint64{tt.key: 1},
+				loadedAt:  time.Now(),
+			}}}
+			h, err := NewDebugHandler(c)
+			if err != nil {
+				t.Fatalf("NewDebugHandler: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/config", nil))
+			var view struct {
+				Keys []struct {
+					Key   string      `json:"key"`
+					Value interface{} `json:"value"`
+				} `json:"keys"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+				t.Fatalf("Unmarshal(%s): %v", w.Body.String(), err)
+			}
+			if len(view.Keys) != 1 || view.Keys[0].Key != tt.key {
+				t.Fatalf("keys = %+v, want only %s", view.Keys, tt.key)
+			}
+			if !reflect.DeepEqual(view.Keys[0].Value, tt.want) {
+				t.Fatalf("value = %#v, want %#v", view.Keys[0].Value, tt.want)
+			}
+		})
+	}
+}
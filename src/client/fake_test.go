@@ -0,0 +1,214 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divideandconquer/go-consul-client/src/config"
+	"github.com/divideandconquer/go-consul-client/src/retry"
+)
+
+// fakeConsul is an in memory stand in for the consul kv http api, it answers blocking queries and check-and-set
+type fakeConsul struct {
+	lock    sync.Mutex
+	pairs   map[string]fakePair
+	index   uint64
+	changed chan struct{} // closed and replaced on every write to wake blocking queries
+	stop    chan struct{}
+	addr    string
+	down    bool     // every request fails while set
+	listed  []string // the prefixes every list request asked for
+}
+
+type fakePair struct {
+	value  []byte
+	modify uint64
+}
+
+// newTestLoader starts a fake consul holding pairs and creates a loader for the namespaces against it
+func newTestLoader(t *testing.T, namespaces []string, pairs map[string]string, opts ...Option) (*cachedLoader, *fakeConsul) {
+	t.Helper()
+	f := &fakeConsul{pairs: make(map[string]fakePair), index: 1, changed: make(chan struct{}), stop: make(chan struct{})}
+	for k, v := range pairs {
+		f.set(k, v)
+	}
+	srv := httptest.NewServer(f)
+	f.addr = strings.TrimPrefix(srv.URL, "http://")
+	t.Cleanup(func() {
+		close(f.stop)
+		srv.Close()
+	})
+	return f.newLoader(t, namespaces, opts...), f
+}
+
+// newLoader creates another loader for the namespaces against the fake
+func (f *fakeConsul) newLoader(t *testing.T, namespaces []string, opts ...Option) *cachedLoader {
+	t.Helper()
+	opts = append([]Option{WithRetryPolicy(retry.Policy{InitialInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 2}), WithWaitTime(time.Second)}, opts...)
+	l, err := NewLayeredLoader(namespaces, f.addr, opts...)
+	if err != nil {
+		t.Fatalf("NewLayeredLoader: %v", err)
+	}
+	return l.(*cachedLoader)
+}
+
+// set writes a pair as if someone else had written it to consul
+func (f *fakeConsul) set(key, value string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.writeLocked(key, []byte(value))
+}
+
+// remove deletes a pair as if someone else had deleted it from consul
+func (f *fakeConsul) remove(key string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.pairs, key)
+	f.index++
+	f.notifyLocked()
+}
+
+// keys lists every key in the fake
+func (f *fakeConsul) keys() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var keys []string
+	for k := range f.pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeConsul) get(key string) (string, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	p, ok := f.pairs[key]
+	return string(p.value), ok
+}
+
+func (f *fakeConsul) setDown(down bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.down = down
+}
+
+func (f *fakeConsul) writeLocked(key string, value []byte) {
+	f.index++
+	f.pairs[key] = fakePair{value: value, modify: f.index}
+	f.notifyLocked()
+}
+
+func (f *fakeConsul) notifyLocked() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	q := r.URL.Query()
+	_, recurse := q["recurse"]
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.down {
+		http.Error(w, "consul is down", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if recurse {
+			f.listed = append(f.listed, key)
+		}
+		f.waitLocked(r)
+		var out []map[string]interface{}
+		var keys []string
+		for k := range f.pairs {
+			if (recurse && strings.HasPrefix(k, key)) || k == key {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, map[string]interface{}{"Key": k, "Value": f.pairs[k].value, "ModifyIndex": f.pairs[k].modify})
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+		if len(out) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(out)
+	case "PUT":
+		b, _ := ioutil.ReadAll(r.Body)
+		if cas := q.Get("cas"); cas != "" {
+			want, _ := strconv.ParseUint(cas, 10, 64)
+			if f.pairs[key].modify != want {
+				w.Write([]byte("false"))
+				return
+			}
+		}
+		f.writeLocked(key, b)
+		w.Write([]byte("true"))
+	case "DELETE":
+		for k := range f.pairs {
+			if (recurse && strings.HasPrefix(k, key)) || k == key {
+				delete(f.pairs, k)
+			}
+		}
+		f.index++
+		f.notifyLocked()
+		w.Write([]byte("true"))
+	}
+}
+
+// waitLocked holds a blocking query until the index moves past the one asked for, like consul does
+func (f *fakeConsul) waitLocked(r *http.Request) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	if index == 0 || index < f.index {
+		return
+	}
+	wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+	if err != nil {
+		wait = time.Second
+	}
+	changed := f.changed
+	f.lock.Unlock()
+	select {
+	case <-changed:
+	case <-time.After(wait):
+	case <-r.Context().Done():
+	case <-f.stop:
+	}
+	f.lock.Lock()
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// mustGetString is GetString failing the test on error
+func mustGetString(t *testing.T, l config.Loader, key string) string {
+	t.Helper()
+	s, err := l.GetString(key)
+	if err != nil {
+		t.Fatalf("GetString(%s): %v", key, err)
+	}
+	return s
+}
@@ -1,76 +1,265 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/divideandconquer/go-consul-client/src/config"
 )
 
 type mockLoader struct {
+	config.Notifier
+
 	data map[string]interface{}
 }
 
 func NewMockLoader(data map[string]interface{}) config.Loader {
-	return &mockLoader{data}
+	return &mockLoader{data: data}
 }
 func (m *mockLoader) Import(data []byte) error {
 	return nil
 }
+func (m *mockLoader) Sync(data []byte) error {
+	return nil
+}
+func (m *mockLoader) ImportPlan(data []byte) ([]config.ChangeEvent, error) {
+	return nil, nil
+}
+func (m *mockLoader) Export() ([]byte, error) {
+	kvs, err := m.kvs()
+	if err != nil {
+		return nil, err
+	}
+	return config.ExportKeys(kvs)
+}
 func (m *mockLoader) Initialize() error {
 	return nil
 }
+func (m *mockLoader) InitializeContext(ctx context.Context) error {
+	return nil
+}
+func (m *mockLoader) Watch(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+func (m *mockLoader) Status() config.Status {
+	return config.Status{}
+}
 func (m *mockLoader) Get(key string) ([]byte, error) {
 	if ret, ok := m.data[key]; ok {
 		if result, ok := ret.([]byte); ok {
 			return result, nil
 		}
 	}
-	return nil, fmt.Errorf("Key (%s) not set in mock.", key)
+	return nil, m.notSet(key)
 }
 
-func (m *mockLoader) MustGetString(key string) string {
+// notSet wraps config.ErrNotFound when key is absent rather than holding a value of another type
+func (m *mockLoader) notSet(key string) error {
+	if _, ok := m.data[key]; !ok {
+		return fmt.Errorf("Key (%s) not set in mock: %w", key, config.ErrNotFound)
+	}
+	return fmt.Errorf("Key (%s) not set in mock.", key)
+}
+
+func (m *mockLoader) Source(key string) (string, error) {
+	if _, ok := m.data[key]; ok {
+		return "mock", nil
+	}
+	return "", m.notSet(key)
+}
+
+func (m *mockLoader) Sub(prefix string) config.Loader {
+	return config.Scope(m, prefix)
+}
+
+func (m *mockLoader) Unmarshal(prefix string, v interface{}) error {
+	kvs, err := m.kvs()
+	if err != nil {
+		return err
+	}
+	return config.UnmarshalKeys(kvs, prefix, v)
+}
+
+// kvs marshals the mock data into the raw json a real loader would hold
+func (m *mockLoader) kvs() (map[string][]byte, error) {
+	kvs := make(map[string][]byte, len(m.data))
+	for k, d := range m.data {
+		if b, ok := d.([]byte); ok {
+			kvs[k] = b
+			continue
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			return nil, fmt.Errorf("Key (%s) in mock could not be marshaled: %v", k, err)
+		}
+		kvs[k] = b
+	}
+	return kvs, nil
+}
+
+func (m *mockLoader) GetString(key string) (string, error) {
 	if ret, ok := m.data[key]; ok {
 		if result, ok := ret.(string); ok {
-			return result
+			return result, nil
 		}
 	}
-	log.Fatalf("Key (%s) not set in mock", key)
-	return ""
+	return "", m.notSet(key)
 }
 
-func (m *mockLoader) MustGetBool(key string) bool {
+func (m *mockLoader) GetBool(key string) (bool, error) {
 	if ret, ok := m.data[key]; ok {
 		if result, ok := ret.(bool); ok {
-			return result
+			return result, nil
 		}
 	}
-	log.Fatalf("Key (%s) not set in mock", key)
-	return false
+	return false, m.notSet(key)
 }
 
-func (m *mockLoader) MustGetInt(key string) int {
+func (m *mockLoader) GetInt(key string) (int, error) {
 	if ret, ok := m.data[key]; ok {
 		if result, ok := ret.(int); ok {
-			return result
+			return result, nil
 		}
 	}
-	log.Fatalf("Key (%s) not set in mock", key)
-	return 0
+	return 0, m.notSet(key)
 }
 
-func (m *mockLoader) MustGetDuration(key string) time.Duration {
+func (m *mockLoader) GetDuration(key string) (time.Duration, error) {
 	if ret, ok := m.data[key]; ok {
 		if result, ok := ret.(time.Duration); ok {
-			return result
+			return result, nil
+		}
+	}
+	return 0, m.notSet(key)
+}
+
+func (m *mockLoader) GetStringSlice(key string) ([]string, error) {
+	if ret, ok := m.data[key]; ok {
+		if result, ok := ret.([]string); ok {
+			return result, nil
+		}
+	}
+	return nil, m.notSet(key)
+}
+
+func (m *mockLoader) GetIntSlice(key string) ([]int, error) {
+	if ret, ok := m.data[key]; ok {
+		if result, ok := ret.([]int); ok {
+			return result, nil
+		}
+	}
+	return nil, m.notSet(key)
+}
+
+func (m *mockLoader) GetStringMap(key string) (map[string]string, error) {
+	if ret, ok := m.data[key]; ok {
+		if result, ok := ret.(map[string]string); ok {
+			return result, nil
 		}
 	}
-	log.Fatalf("Key (%s) not set in mock", key)
-	return 0
+	return nil, m.notSet(key)
+}
+
+func (m *mockLoader) GetStringOr(key string, def string) string {
+	if result, err := m.GetString(key); err == nil {
+		return result
+	}
+	return def
+}
+
+func (m *mockLoader) GetBoolOr(key string, def bool) bool {
+	if result, err := m.GetBool(key); err == nil {
+		return result
+	}
+	return def
+}
+
+func (m *mockLoader) GetIntOr(key string, def int) int {
+	if result, err := m.GetInt(key); err == nil {
+		return result
+	}
+	return def
+}
+
+func (m *mockLoader) GetDurationOr(key string, def time.Duration) time.Duration {
+	if result, err := m.GetDuration(key); err == nil {
+		return result
+	}
+	return def
+}
+
+func (m *mockLoader) MustGetString(key string) string {
+	result, err := m.GetString(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+func (m *mockLoader) MustGetBool(key string) bool {
+	result, err := m.GetBool(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+func (m *mockLoader) MustGetInt(key string) int {
+	result, err := m.GetInt(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+func (m *mockLoader) MustGetDuration(key string) time.Duration {
+	result, err := m.GetDuration(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+func (m *mockLoader) MustGetStringSlice(key string) []string {
+	result, err := m.GetStringSlice(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+func (m *mockLoader) MustGetIntSlice(key string) []int {
+	result, err := m.GetIntSlice(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+func (m *mockLoader) MustGetStringMap(key string) map[string]string {
+	result, err := m.GetStringMap(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
 }
 
 func (m *mockLoader) Put(key string, value []byte) error {
 	return errors.New("Not implemented")
 }
+
+func (m *mockLoader) PutCAS(key string, value []byte) error {
+	return errors.New("Not implemented")
+}
+
+func (m *mockLoader) Delete(key string) error {
+	return errors.New("Not implemented")
+}
+
+func (m *mockLoader) DeleteTree(prefix string) error {
+	return errors.New("Not implemented")
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/divideandconquer/go-consul-client/src/consulconfig"
+	"github.com/divideandconquer/go-consul-client/src/instrument"
+	"github.com/divideandconquer/go-consul-client/src/logging"
+	"github.com/divideandconquer/go-consul-client/src/retry"
+	"github.com/divideandconquer/go-consul-client/src/secret"
+	"github.com/hashicorp/consul/api"
+)
+
+// Option configures optional behaviour of the loaders created by NewCachedLoader and NewLayeredLoader.
+// Options are applied in order so later options override earlier ones.
+type Option func(*cachedLoader)
+
+// WithRetryPolicy sets how Initialize retries consul when it can't be reached, the default is retry.DefaultPolicy
+func WithRetryPolicy(p retry.Policy) Option {
+	return func(c *cachedLoader) {
+		c.retry = p
+	}
+}
+
+// WithInstrumentation records metrics and spans with instr, the default discards them.
+// The loader records loader_consul_requests_total and loader_consul_request_seconds per request to consul,
+// loader_reloads_total for each change Watch loads or rejects and a loader_initialize span around
+// InitializeContext.  loader_gets_total with a hit or miss result is only recorded by instrumentations that
+// implement instrument.CounterFuncs, such as the Prometheus adapter, as Get is too hot to report every read.
+func WithInstrumentation(instr instrument.Instrumentation) Option {
+	return func(c *cachedLoader) {
+		c.instr = instr
+	}
+}
+
+// WithLogger logs loads, reloads and failures to reach consul to logger, the default discards them
+func WithLogger(logger logging.Logger) Option {
+	return func(c *cachedLoader) {
+		c.logger = logger
+	}
+}
+
+// WithKeyring decrypts values that were encrypted with the secret package, e.g. by the importer's -encrypt flag.
+// Put and PutCAS encrypt the new value of a key whose current value is encrypted, without a keyring they fail.
+func WithKeyring(k *secret.Keyring) Option {
+	return func(c *cachedLoader) {
+		c.keyring = k
+	}
+}
+
+// WithConsulClient uses an existing consul client, e.g. one shared with a balancer.
+// The address and every other consul option are ignored.
+func WithConsulClient(client *api.Client) Option {
+	return func(c *cachedLoader) {
+		c.consulClient = client
+	}
+}
+
+// WithConsulConfig builds the consul client from a copy of conf instead of api.DefaultConfig().
+// The consulAddr passed to the constructor is used when conf has no Address.
+func WithConsulConfig(conf *api.Config) Option {
+	return withConsul(consulconfig.Replace(conf))
+}
+
+// WithToken sets the ACL token sent with every request
+func WithToken(token string) Option {
+	return withConsul(consulconfig.Token(token))
+}
+
+// WithDatacenter reads and writes the KV store of the given datacenter instead of the agent's
+func WithDatacenter(dc string) Option {
+	return withConsul(consulconfig.Datacenter(dc))
+}
+
+// WithHTTPAuth sets the http basic auth credentials sent with every request
+func WithHTTPAuth(username, password string) Option {
+	return withConsul(consulconfig.HTTPAuth(username, password))
+}
+
+// WithWaitTime limits how long consul may hold the blocking queries made by Watch open, the default is 5 minutes
+func WithWaitTime(wait time.Duration) Option {
+	return func(c *cachedLoader) {
+		c.waitTime = wait
+	}
+}
+
+// WithHTTPClient sends requests to consul with client, e.g. one shared with a balancer
+func WithHTTPClient(client *http.Client) Option {
+	return withConsul(consulconfig.HTTPClient(client))
+}
+
+// WithTLSConfig talks to consul over https using conf for the client certificates and trusted CAs
+func WithTLSConfig(conf *tls.Config) Option {
+	return withConsul(consulconfig.TLSConfig(conf))
+}
+
+// withConsul applies a consul config option to the config the consul client is built from
+func withConsul(opt consulconfig.Option) Option {
+	return func(c *cachedLoader) {
+		opt(c.consulConfig)
+	}
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/divideandconquer/go-consul-client/src/config"
+)
+
+// WithSchema validates the config against schema, always merged across every namespace as Get would see it.
+// Import, Sync and ImportPlan reject documents that would leave the first namespace in consul not matching it
+// once merged with the cached namespaces below, Put and PutCAS reject values that would leave the cache not
+// matching it, and Initialize and Watch refuse to load a version of the namespaces that doesn't match it so the
+// cache keeps the last valid config.
+func WithSchema(s *config.Schema) Option {
+	return func(c *cachedLoader) {
+		c.schema = s
+	}
+}
+
+// validateLayers validates the config the cache would hold once the updated layers replace the current ones
+func (c *cachedLoader) validateLayers(updated ...*layer) error {
+	if c.schema == nil {
+		return nil
+	}
+
+	c.cacheLock.RLock()
+	kvs := make(map[string][]byte)
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		l := c.layers[i]
+		for _, u := range updated {
+			if u.namespace == l.namespace {
+				l = u
+			}
+		}
+		for k, v := range l.pairs {
+			kvs[k] = v
+		}
+	}
+	c.cacheLock.RUnlock()
+
+	return c.validate(kvs)
+}
+
+// validateImport validates the config the cache would hold once the imported consul keys are written on top
+// of current, the first namespace as it is in consul, or replace it when prune is set
+func (c *cachedLoader) validateImport(current, imported map[string][]byte, prune bool) error {
+	if c.schema == nil {
+		return nil
+	}
+	primary := &layer{namespace: c.namespaces[0], pairs: make(map[string][]byte)}
+	if !prune {
+		for k, v := range current {
+			if key, ok := relativeKey(c.namespaces[0], k); ok && !c.ownedByNestedNamespace(c.namespaces[0], k) {
+				primary.pairs[key] = v
+			}
+		}
+	}
+	for k, v := range imported {
+		if key, ok := relativeKey(c.namespaces[0], k); ok {
+			primary.pairs[key] = v
+		}
+	}
+	return c.validateLayers(primary)
+}
+
+// validatePut validates the config the cache would hold once key is set to value
+func (c *cachedLoader) validatePut(key string, value []byte) error {
+	if c.schema == nil {
+		return nil
+	}
+	c.cacheLock.RLock()
+	primary := &layer{namespace: c.namespaces[0], pairs: make(map[string][]byte, len(c.layers[0].pairs)+1)}
+	for k, v := range c.layers[0].pairs {
+		primary.pairs[k] = v
+	}
+	c.cacheLock.RUnlock()
+
+	primary.pairs[key] = value
+	return c.validateLayers(primary)
+}
+
+// validate checks the keys against the schema as Get would return them, decrypted and interpolated.
+// Values that can't be decrypted or interpolated are validated as they are stored.
+func (c *cachedLoader) validate(kvs map[string][]byte) error {
+	get := func(key string) ([]byte, error) {
+		v, ok := kvs[key]
+		if !ok {
+			return nil, fmt.Errorf("Could not find value for key: %s", key)
+		}
+		return c.keyring.Decrypt(key, v)
+	}
+
+	resolved := make(map[string][]byte, len(kvs))
+	for k, v := range kvs {
+		resolved[k] = v
+		if plain, err := get(k); err == nil {
+			resolved[k] = plain
+			if interpolated, err := config.Interpolate(k, plain, get); err == nil {
+				resolved[k] = interpolated
+			}
+		}
+	}
+	return c.schema.ValidateKeys(resolved)
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/divideandconquer/go-consul-client/src/logging"
+)
+
+// WithSnapshot persists the cache to path after every successful load from consul.  When consul can't be
+// reached on Initialize the cache is loaded from the snapshot instead and Status reports it as stale until
+// Watch reloads it from consul.  The snapshot holds every cached value so path should not be world readable.
+func WithSnapshot(path string) Option {
+	return func(c *cachedLoader) {
+		c.snapshotPath = path
+	}
+}
+
+type snapshot struct {
+	Layers []snapshotLayer `json:"layers"`
+}
+
+type snapshotLayer struct {
+	Namespace string            `json:"namespace"`
+	Pairs     map[string][]byte `json:"pairs"`
+	Modified  map[string]uint64 `json:"modified"`
+	Index     uint64            `json:"index"`
+	LoadedAt  time.Time         `json:"loaded_at"`
+}
+
+// saveSnapshot writes the cache to the snapshot file, failures are logged as the cache itself is fine
+func (c *cachedLoader) saveSnapshot() {
+	if c.snapshotPath == "" {
+		return
+	}
+	err := c.writeSnapshot()
+	if err != nil {
+		c.logger.Warn("Could not write config snapshot", logging.Fields{"snapshot": c.snapshotPath, "error": err})
+	}
+}
+
+func (c *cachedLoader) writeSnapshot() error {
+	//one writer at a time so a slower write can't replace a newer snapshot
+	c.snapshotLock.Lock()
+	defer c.snapshotLock.Unlock()
+
+	var s snapshot
+	c.cacheLock.RLock()
+	for _, l := range c.layers {
+		s.Layers = append(s.Layers, snapshotLayer{
+			Namespace: l.namespace,
+			Pairs:     l.pairs,
+			Modified:  l.modified,
+			Index:     l.index,
+			LoadedAt:  l.loadedAt,
+		})
+	}
+	data, err := json.Marshal(s)
+	c.cacheLock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	//write to a temp file and rename it so a crash never leaves a partial snapshot behind
+	tmp, err := ioutil.TempFile(filepath.Dir(c.snapshotPath), filepath.Base(c.snapshotPath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.snapshotPath)
+}
+
+// readSnapshot loads the layers from the snapshot file flagged as stale
+func (c *cachedLoader) readSnapshot() ([]*layer, error) {
+	data, err := ioutil.ReadFile(c.snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read config snapshot (%s) %v", c.snapshotPath, err)
+	}
+	var s snapshot
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse config snapshot (%s) %v", c.snapshotPath, err)
+	}
+
+	layers := make([]*layer, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		found := false
+		for _, sl := range s.Layers {
+			if sl.Namespace != ns {
+				continue
+			}
+			l := &layer{
+				namespace: ns,
+				pairs:     sl.Pairs,
+				modified:  sl.Modified,
+				index:     sl.Index,
+				loadedAt:  sl.LoadedAt,
+				stale:     true,
+			}
+			if l.pairs == nil {
+				l.pairs = make(map[string][]byte)
+			}
+			if l.modified == nil {
+				l.modified = make(map[string]uint64)
+			}
+			layers = append(layers, l)
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("Config snapshot (%s) does not contain namespace %s", c.snapshotPath, ns)
+		}
+	}
+	return layers, nil
+}
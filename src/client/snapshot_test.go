@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.snapshot")
+	c, f := newTestLoader(t, []string{"app", "base"}, map[string]string{"app/host": `"a"`, "base/port": `"1"`}, WithSnapshot(path))
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("snapshot was not written: %v", err)
+	}
+
+	//consul goes down and a new loader boots from the snapshot
+	f.setDown(true)
+	stale := f.newLoader(t, []string{"app", "base"}, WithSnapshot(path))
+	if err := stale.Initialize(); err != nil {
+		t.Fatalf("Initialize from the snapshot: %v", err)
+	}
+	if !stale.Status().Stale {
+		t.Fatal("Status is not stale after booting from the snapshot")
+	}
+	if got := mustGetString(t, stale, "host"); got != "a" {
+		t.Fatalf("GetString(host) = %s, want a", got)
+	}
+	if got := mustGetString(t, stale, "port"); got != "1" {
+		t.Fatalf("GetString(port) = %s, want 1", got)
+	}
+
+	//without a snapshot there is nothing to fall back on
+	missing := f.newLoader(t, []string{"app"}, WithSnapshot(filepath.Join(t.TempDir(), "missing")))
+	if err := missing.Initialize(); err == nil {
+		t.Fatal("Initialize succeeded without consul or a snapshot")
+	}
+	other := f.newLoader(t, []string{"other"}, WithSnapshot(path))
+	if err := other.Initialize(); err == nil {
+		t.Fatal("Initialize succeeded from a snapshot without the namespace")
+	}
+
+	//once consul is back Watch refreshes the stale cache without waiting for a change
+	f.setDown(false)
+	f.set("app/host", `"b"`)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- stale.Watch(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitFor(t, "the stale cache to refresh", func() bool { return !stale.Status().Stale })
+	if got := mustGetString(t, stale, "host"); got != "b" {
+		t.Fatalf("GetString(host) = %s, want b", got)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"gopkg.in/yaml.v3"
+)
+
+// Decoder parses a config document into nested maps.  Nested maps are flattened into a/b/c keys on Import.
+type Decoder func(data []byte) (map[string]interface{}, error)
+
+var (
+	decodersLock sync.RWMutex
+	decoders     = map[string]Decoder{
+		"json": decodeJSON,
+		"yaml": decodeYAML,
+		"yml":  decodeYAML,
+		"toml": decodeTOML,
+		"hcl":  decodeHCL,
+	}
+)
+
+// RegisterDecoder makes a Decoder available for the given format, replacing any existing decoder for it
+func RegisterDecoder(format string, d Decoder) {
+	decodersLock.Lock()
+	defer decodersLock.Unlock()
+	decoders[strings.ToLower(format)] = d
+}
+
+// FormatForFile returns the format of a file based on its extension.  Extensions without a registered decoder,
+// such as .conf or .bak, default to json as every file was json before other formats were supported.
+func FormatForFile(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+
+	decodersLock.RLock()
+	_, ok := decoders[ext]
+	decodersLock.RUnlock()
+	if !ok {
+		return "json"
+	}
+	return ext
+}
+
+// ToJSON converts a document in the given format into the json that Import accepts, so every format produces
+// the same flattened keys as the equivalent json document.
+func ToJSON(format string, data []byte) ([]byte, error) {
+	decodersLock.RLock()
+	d, ok := decoders[strings.ToLower(format)]
+	decodersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unsupported config format: %s", format)
+	}
+
+	conf, err := d(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s data: %v", format, err)
+	}
+	ret, err := json.Marshal(conf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to convert %s data to json: %v", format, err)
+	}
+	return ret, nil
+}
+
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	conf := make(map[string]interface{})
+	err := json.Unmarshal(data, &conf)
+	return conf, err
+}
+
+func decodeYAML(data []byte) (map[string]interface{}, error) {
+	conf := make(map[string]interface{})
+	err := yaml.Unmarshal(data, &conf)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeMap(conf, false), nil
+}
+
+func decodeTOML(data []byte) (map[string]interface{}, error) {
+	conf := make(map[string]interface{})
+	_, err := toml.Decode(string(data), &conf)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeMap(conf, false), nil
+}
+
+func decodeHCL(data []byte) (map[string]interface{}, error) {
+	conf := make(map[string]interface{})
+	err := hcl.Unmarshal(data, &conf)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeMap(conf, true), nil
+}
+
+// normalizeMap converts what the yaml, toml and hcl libraries produce into the shapes encoding/json produces
+// so nested objects are flattened the same way.  hcl decodes every block as a list of objects, mergeBlocks
+// merges those lists back into a single object.
+func normalizeMap(m map[string]interface{}, mergeBlocks bool) map[string]interface{} {
+	for k, v := range m {
+		m[k] = normalize(v, mergeBlocks)
+	}
+	return m
+}
+
+func normalize(v interface{}, mergeBlocks bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return normalizeMap(t, mergeBlocks)
+	case map[interface{}]interface{}:
+		//yaml allows non string keys
+		m := make(map[string]interface{}, len(t))
+		for k, sub := range t {
+			m[fmt.Sprint(k)] = normalize(sub, mergeBlocks)
+		}
+		return m
+	case []map[string]interface{}:
+		if !mergeBlocks {
+			l := make([]interface{}, len(t))
+			for i := range t {
+				l[i] = normalizeMap(t[i], mergeBlocks)
+			}
+			return l
+		}
+		m := make(map[string]interface{})
+		for _, block := range t {
+			for k, sub := range normalizeMap(block, mergeBlocks) {
+				m[k] = sub
+			}
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = normalize(t[i], mergeBlocks)
+		}
+		return t
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToJSONMatchesJSON(t *testing.T) {
+	docs := map[string]string{
+		"json": `{"name": "app", "debug": true, "ratio": 1.5, "hosts": ["a", "b"],
+			"database": {"host": "db", "port": 5432, "tls": {"ca": "ca.pem"}}}`,
+		"yaml": `
+name: app
+debug: true
+ratio: 1.5
+hosts: [a, b]
+database:
+  host: db
+  port: 5432
+  tls:
+    ca: ca.pem
+`,
+		"toml": `
+name = "app"
+debug = true
+ratio = 1.5
+hosts = ["a", "b"]
+
+[database]
+host = "db"
+port = 5432
+
+[database.tls]
+ca = "ca.pem"
+`,
+		"hcl": `
+name = "app"
+debug = true
+ratio = 1.5
+hosts = ["a", "b"]
+
+database {
+  host = "db"
+  port = 5432
+  tls {
+    ca = "ca.pem"
+  }
+}
+`,
+	}
+
+	want := flattenDoc(t, "json", docs["json"])
+	for _, format := range []string{"yaml", "toml", "hcl"} {
+		t.Run(format, func(t *testing.T) {
+			if got := flattenDoc(t, format, docs[format]); !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s flattens to %v, want %v", format, got, want)
+			}
+		})
+	}
+}
+
+// flattenDoc converts the document to json and flattens it into the keys Import would store
+func flattenDoc(t *testing.T, format, doc string) map[string]string {
+	t.Helper()
+	data, err := ToJSON(format, []byte(doc))
+	if err != nil {
+		t.Fatalf("ToJSON(%s): %v", format, err)
+	}
+	conf, err := decodeJSON(data)
+	if err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	kvs := make(map[string][]byte)
+	if err := flattenInto(kvs, "", conf); err != nil {
+		t.Fatalf("flattenInto: %v", err)
+	}
+	ret := make(map[string]string, len(kvs))
+	for k, v := range kvs {
+		ret[k] = string(v)
+	}
+	return ret
+}
+
+func TestToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		doc     string
+		wantErr string
+	}{
+		{name: "unknown format", format: "ini", doc: `a = 1`, wantErr: "Unsupported config format: ini"},
+		{name: "bad yaml", format: "yaml", doc: "a: [1", wantErr: "Unable to parse yaml data"},
+		{name: "bad toml", format: "toml", doc: "a = ", wantErr: "Unable to parse toml data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ToJSON(tt.format, []byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ToJSON error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatForFile(t *testing.T) {
+	tests := map[string]string{
+		"config.json":     "json",
+		"config.YAML":     "yaml",
+		"config.yml":      "yml",
+		"config.toml":     "toml",
+		"config.hcl":      "hcl",
+		"config.conf":     "json",
+		"config.yaml.bak": "json",
+		"config":          "json",
+	}
+	for path, want := range tests {
+		if got := FormatForFile(path); got != want {
+			t.Errorf("FormatForFile(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
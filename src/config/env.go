@@ -0,0 +1,375 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// EnvMapping maps a config key to the name of the environment variable that overrides it
+type EnvMapping func(key string) string
+
+// EnvPrefixMapping upper cases the key, replaces / - and . with _ and prepends the prefix,
+// so with the prefix APP the key database/host is overridden by APP_DATABASE_HOST
+func EnvPrefixMapping(prefix string) EnvMapping {
+	replacer := strings.NewReplacer("/", "_", "-", "_", ".", "_")
+	return func(key string) string {
+		name := strings.ToUpper(replacer.Replace(strings.Trim(key, "/")))
+		if prefix == "" {
+			return name
+		}
+		return strings.ToUpper(prefix) + "_" + name
+	}
+}
+
+type envLoader struct {
+	parent  Loader
+	mapping EnvMapping
+}
+
+// NewEnvLoader wraps parent so every key is first looked up in the environment using EnvPrefixMapping(prefix)
+func NewEnvLoader(parent Loader, prefix string) Loader {
+	return NewEnvLoaderWithMapping(parent, EnvPrefixMapping(prefix))
+}
+
+// NewEnvLoaderWithMapping wraps parent so every key is first looked up in the environment variable named by mapping.
+// Values are used as json when they parse as json and as plain strings otherwise, and may reference other keys
+// like any other string value, see Interpolate.
+// Writes, imports and exports go straight to parent.
+func NewEnvLoaderWithMapping(parent Loader, mapping EnvMapping) Loader {
+	return &envLoader{parent: parent, mapping: mapping}
+}
+
+// lookup returns the json for key from the environment, plain strings are encoded as json strings
+func (e *envLoader) lookup(key string) ([]byte, string, bool) {
+	name := e.mapping(key)
+	raw, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, name, false
+	}
+	if json.Valid([]byte(raw)) {
+		return []byte(raw), name, true
+	}
+	b, _ := json.Marshal(raw)
+	return b, name, true
+}
+
+func (e *envLoader) Import(data []byte) error {
+	return e.parent.Import(data)
+}
+
+func (e *envLoader) merge(data []byte) error {
+	return importMerged(e.parent, data)
+}
+
+func (e *envLoader) Sync(data []byte) error {
+	return e.parent.Sync(data)
+}
+
+func (e *envLoader) ImportPlan(data []byte) ([]ChangeEvent, error) {
+	return e.parent.ImportPlan(data)
+}
+
+func (e *envLoader) Export() ([]byte, error) {
+	return e.parent.Export()
+}
+
+func (e *envLoader) Initialize() error {
+	return e.parent.Initialize()
+}
+
+func (e *envLoader) InitializeContext(ctx context.Context) error {
+	return e.parent.InitializeContext(ctx)
+}
+
+func (e *envLoader) Watch(ctx context.Context) error {
+	return e.parent.Watch(ctx)
+}
+
+func (e *envLoader) Status() Status {
+	return e.parent.Status()
+}
+
+// Get returns the environment override for key if there is one and the parent's value otherwise.  References
+// to other keys are resolved with the overrides applied, in overrides as well as in the parent's values when
+// the parent implements RawGetter.
+func (e *envLoader) Get(key string) ([]byte, error) {
+	_, overridden := os.LookupEnv(e.mapping(key))
+	if _, ok := e.parent.(RawGetter); !ok && !overridden {
+		return e.parent.Get(key)
+	}
+	b, err := e.GetRaw(key)
+	if err != nil {
+		return nil, err
+	}
+	return Interpolate(key, b, e.GetRaw)
+}
+
+// GetRaw is Get without resolving references to other keys
+func (e *envLoader) GetRaw(key string) ([]byte, error) {
+	if b, _, ok := e.lookup(key); ok {
+		return b, nil
+	}
+	if raw, ok := e.parent.(RawGetter); ok {
+		return raw.GetRaw(key)
+	}
+	return e.parent.Get(key)
+}
+
+func (e *envLoader) Put(key string, value []byte) error {
+	return e.parent.Put(key, value)
+}
+
+func (e *envLoader) PutCAS(key string, value []byte) error {
+	return e.parent.PutCAS(key, value)
+}
+
+func (e *envLoader) Delete(key string) error {
+	return e.parent.Delete(key)
+}
+
+func (e *envLoader) DeleteTree(prefix string) error {
+	return e.parent.DeleteTree(prefix)
+}
+
+// Source reports env:NAME for keys overridden by the environment
+func (e *envLoader) Source(key string) (string, error) {
+	if _, name, ok := e.lookup(key); ok {
+		return "env:" + name, nil
+	}
+	return e.parent.Source(key)
+}
+
+func (e *envLoader) Sub(prefix string) Loader {
+	return Scope(e, prefix)
+}
+
+// Unmarshal applies environment overrides to the parent's keys beneath prefix and to the keys of the fields of v
+func (e *envLoader) Unmarshal(prefix string, v interface{}) error {
+	existing := make(map[string]interface{})
+	err := e.parent.Unmarshal(prefix, &existing)
+	if err != nil {
+		return err
+	}
+
+	prefix = strings.Trim(prefix, "/")
+	kvs := make(map[string][]byte)
+	err = flattenInto(kvs, prefix, existing)
+	if err != nil {
+		return err
+	}
+
+	candidates := structKeys(reflect.TypeOf(v), prefix)
+	for k := range kvs {
+		candidates = append(candidates, k)
+	}
+	for _, k := range candidates {
+		if b, _, ok := e.lookup(k); ok {
+			b, err = Interpolate(k, b, e.GetRaw)
+			if err != nil {
+				return err
+			}
+			kvs[k] = b
+		}
+	}
+	return UnmarshalKeys(kvs, prefix, v)
+}
+
+func (e *envLoader) OnChange(keyOrPrefix string, fn ChangeFunc) func() {
+	return e.parent.OnChange(keyOrPrefix, fn)
+}
+
+func (e *envLoader) OnChanges(keyOrPrefix string, fn ChangesFunc) func() {
+	return e.parent.OnChanges(keyOrPrefix, fn)
+}
+
+func (e *envLoader) Subscribe(keyOrPrefix string) (<-chan ChangeEvent, func()) {
+	return e.parent.Subscribe(keyOrPrefix)
+}
+
+// GetString is lenient with overrides, an override that is json but not a json string is returned as is
+func (e *envLoader) GetString(key string) (string, error) {
+	if _, _, ok := e.lookup(key); ok {
+		b, err := e.Get(key)
+		if err != nil {
+			return "", err
+		}
+		var s string
+		if json.Unmarshal(b, &s) == nil {
+			return s, nil
+		}
+		return string(b), nil
+	}
+	if _, ok := e.parent.(RawGetter); ok {
+		//the parent's references may point at overridden keys
+		return getString(e.Get, key)
+	}
+	return e.parent.GetString(key)
+}
+
+func (e *envLoader) GetBool(key string) (bool, error) {
+	if _, _, ok := e.lookup(key); ok {
+		return getBool(e.Get, key)
+	}
+	return e.parent.GetBool(key)
+}
+
+func (e *envLoader) GetInt(key string) (int, error) {
+	if _, _, ok := e.lookup(key); ok {
+		return getInt(e.Get, key)
+	}
+	return e.parent.GetInt(key)
+}
+
+func (e *envLoader) GetDuration(key string) (time.Duration, error) {
+	s, err := e.GetString(key)
+	if err != nil {
+		return 0, err
+	}
+	ret, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("Could not parse config (%s) into a duration: %v", key, err)
+	}
+	return ret, nil
+}
+
+func (e *envLoader) GetStringSlice(key string) ([]string, error) {
+	var ret []string
+	found, err := e.decodeOverride(key, &ret)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return ret, nil
+	}
+	return e.parent.GetStringSlice(key)
+}
+
+func (e *envLoader) GetIntSlice(key string) ([]int, error) {
+	var ret []int
+	found, err := e.decodeOverride(key, &ret)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return ret, nil
+	}
+	return e.parent.GetIntSlice(key)
+}
+
+func (e *envLoader) GetStringMap(key string) (map[string]string, error) {
+	var ret map[string]string
+	found, err := e.decodeOverride(key, &ret)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return ret, nil
+	}
+	return e.parent.GetStringMap(key)
+}
+
+// decodeOverride decodes the environment override for key into v and reports whether there was one
+func (e *envLoader) decodeOverride(key string, v interface{}) (bool, error) {
+	b, _, ok := e.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	err := json.Unmarshal(b, v)
+	if err != nil {
+		return true, fmt.Errorf("Could not unmarshal config (%s) %v", key, err)
+	}
+	return true, nil
+}
+
+func (e *envLoader) GetStringOr(key string, def string) string {
+	s, err := e.GetString(key)
+	if err != nil {
+		return def
+	}
+	return s
+}
+
+func (e *envLoader) GetBoolOr(key string, def bool) bool {
+	ret, err := e.GetBool(key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+func (e *envLoader) GetIntOr(key string, def int) int {
+	ret, err := e.GetInt(key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+func (e *envLoader) GetDurationOr(key string, def time.Duration) time.Duration {
+	ret, err := e.GetDuration(key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+func (e *envLoader) MustGetString(key string) string {
+	s, err := e.GetString(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return s
+}
+
+func (e *envLoader) MustGetBool(key string) bool {
+	ret, err := e.GetBool(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+func (e *envLoader) MustGetInt(key string) int {
+	ret, err := e.GetInt(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+func (e *envLoader) MustGetDuration(key string) time.Duration {
+	ret, err := e.GetDuration(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+func (e *envLoader) MustGetStringSlice(key string) []string {
+	ret, err := e.GetStringSlice(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+func (e *envLoader) MustGetIntSlice(key string) []int {
+	ret, err := e.GetIntSlice(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+func (e *envLoader) MustGetStringMap(key string) map[string]string {
+	ret, err := e.GetStringMap(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
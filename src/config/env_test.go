@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnvLoaderInterpolation(t *testing.T) {
+	parent, err := NewMappedLoader([]byte(`{"database": {"host": "consul-host", "port": 5432, "url": "${database/host}:${database/port}"}, "greeting": "hi ${name}", "name": "consul"}`))
+	if err != nil {
+		t.Fatalf("NewMappedLoader: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		key     string
+		want    string
+		wantErr string
+	}{
+		{name: "parent value", key: "database/url", want: "consul-host:5432"},
+		{name: "parent value referencing an override", env: map[string]string{"ENVTEST_DATABASE_HOST": "env-host"}, key: "database/url", want: "env-host:5432"},
+		{name: "override", env: map[string]string{"ENVTEST_DATABASE_URL": "plain"}, key: "database/url", want: "plain"},
+		{name: "override referencing the parent", env: map[string]string{"ENVTEST_DATABASE_URL": "${database/host}:2"}, key: "database/url", want: "consul-host:2"},
+		{name: "override referencing an override", env: map[string]string{"ENVTEST_DATABASE_URL": "${database/host}:2", "ENVTEST_DATABASE_HOST": "env-host"}, key: "database/url", want: "env-host:2"},
+		{name: "escaped override", env: map[string]string{"ENVTEST_NAME": "$${name}"}, key: "name", want: "${name}"},
+		{name: "cycle through an override", env: map[string]string{"ENVTEST_NAME": "${greeting}"}, key: "greeting", wantErr: "Reference cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			e := NewEnvLoader(parent, "ENVTEST")
+
+			got, err := e.GetString(tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetString error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetString: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("GetString = %s, want %s", got, tt.want)
+			}
+			if b, err := e.Get(tt.key); err != nil || string(b) != `"`+tt.want+`"` {
+				t.Fatalf("Get = %s, %v, want %q", b, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// handle keeps the current value of a key in an atomic.Value, re-reading it whenever the loader changes
+type handle struct {
+	loader   Loader
+	key      string
+	def      interface{}
+	read     func(key string) (interface{}, error)
+	validate func(v interface{}) error
+
+	value       atomic.Value
+	lock        sync.Mutex
+	lastErr     error
+	unsubscribe func()
+}
+
+func newHandle(l Loader, key string, def interface{}, read func(string) (interface{}, error), validate func(interface{}) error) *handle {
+	h := &handle{loader: l, key: key, def: def, read: read, validate: validate}
+	h.value.Store(def)
+	//every change is followed rather than just key as the value may reference other keys
+	h.unsubscribe = l.OnChanges("", func(events []ChangeEvent) {
+		h.refresh()
+	})
+	h.refresh()
+	return h
+}
+
+// refresh reads the key and stores it when it is valid.  A missing key reverts to the default while a value
+// that can't be read, parsed or fails validation keeps the last good value.
+func (h *handle) refresh() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	v, err := h.read(h.key)
+	if errors.Is(err, ErrNotFound) {
+		h.value.Store(h.def)
+		h.lastErr = nil
+		return
+	}
+	if err == nil && h.validate != nil {
+		if verr := h.validate(v); verr != nil {
+			err = fmt.Errorf("Invalid config (%s) %v", h.key, verr)
+		}
+	}
+	h.lastErr = err
+	if err == nil {
+		h.value.Store(v)
+	}
+}
+
+func (h *handle) err() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.lastErr
+}
+
+// StringHandle is a string key that is kept up to date as the loader reloads
+type StringHandle struct{ h *handle }
+
+// Get returns the current value without locking
+func (s *StringHandle) Get() string { return s.h.value.Load().(string) }
+
+// Err returns why the latest value of the key was rejected, nil if Get returns the latest value
+func (s *StringHandle) Err() error { return s.h.err() }
+
+// Close stops following changes, Get keeps returning the last value
+func (s *StringHandle) Close() { s.h.unsubscribe() }
+
+// IntHandle is an int key that is kept up to date as the loader reloads
+type IntHandle struct{ h *handle }
+
+// Get returns the current value without locking
+func (i *IntHandle) Get() int { return i.h.value.Load().(int) }
+
+// Err returns why the latest value of the key was rejected, nil if Get returns the latest value
+func (i *IntHandle) Err() error { return i.h.err() }
+
+// Close stops following changes, Get keeps returning the last value
+func (i *IntHandle) Close() { i.h.unsubscribe() }
+
+// BoolHandle is a bool key that is kept up to date as the loader reloads
+type BoolHandle struct{ h *handle }
+
+// Get returns the current value without locking
+func (b *BoolHandle) Get() bool { return b.h.value.Load().(bool) }
+
+// Err returns why the latest value of the key was rejected, nil if Get returns the latest value
+func (b *BoolHandle) Err() error { return b.h.err() }
+
+// Close stops following changes, Get keeps returning the last value
+func (b *BoolHandle) Close() { b.h.unsubscribe() }
+
+// DurationHandle is a duration key that is kept up to date as the loader reloads
+type DurationHandle struct{ h *handle }
+
+// Get returns the current value without locking
+func (d *DurationHandle) Get() time.Duration { return d.h.value.Load().(time.Duration) }
+
+// Err returns why the latest value of the key was rejected, nil if Get returns the latest value
+func (d *DurationHandle) Err() error { return d.h.err() }
+
+// Close stops following changes, Get keeps returning the last value
+func (d *DurationHandle) Close() { d.h.unsubscribe() }
+
+// NewStringHandle follows key in l, Get returns def while the key is missing.
+// A new value that fails any of the validators is rejected and the last good value kept.
+func NewStringHandle(l Loader, key string, def string, validators ...func(string) error) *StringHandle {
+	return &StringHandle{h: newHandle(l, key, def,
+		func(key string) (interface{}, error) { return l.GetString(key) },
+		func(v interface{}) error {
+			for _, validate := range validators {
+				if err := validate(v.(string)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})}
+}
+
+// NewIntHandle follows key in l, Get returns def while the key is missing.
+// A new value that fails any of the validators is rejected and the last good value kept.
+func NewIntHandle(l Loader, key string, def int, validators ...func(int) error) *IntHandle {
+	return &IntHandle{h: newHandle(l, key, def,
+		func(key string) (interface{}, error) { return l.GetInt(key) },
+		func(v interface{}) error {
+			for _, validate := range validators {
+				if err := validate(v.(int)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})}
+}
+
+// NewBoolHandle follows key in l, Get returns def while the key is missing.
+// A new value that fails any of the validators is rejected and the last good value kept.
+func NewBoolHandle(l Loader, key string, def bool, validators ...func(bool) error) *BoolHandle {
+	return &BoolHandle{h: newHandle(l, key, def,
+		func(key string) (interface{}, error) { return l.GetBool(key) },
+		func(v interface{}) error {
+			for _, validate := range validators {
+				if err := validate(v.(bool)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})}
+}
+
+// NewDurationHandle follows key in l, Get returns def while the key is missing.
+// A new value that fails any of the validators is rejected and the last good value kept.
+func NewDurationHandle(l Loader, key string, def time.Duration, validators ...func(time.Duration) error) *DurationHandle {
+	return &DurationHandle{h: newHandle(l, key, def,
+		func(key string) (interface{}, error) { return l.GetDuration(key) },
+		func(v interface{}) error {
+			for _, validate := range validators {
+				if err := validate(v.(time.Duration)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})}
+}
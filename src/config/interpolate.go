@@ -0,0 +1,86 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RawGetter is implemented by loaders that can fetch a value without resolving its references.  Loaders that
+// wrap another one, such as NewEnvLoader, use it to resolve references themselves against the keys they override.
+type RawGetter interface {
+	// GetRaw is Get without resolving references to other keys
+	GetRaw(key string) ([]byte, error)
+}
+
+// Interpolate resolves references to other keys in a json string value, so "${database/host}:${database/port}"
+// becomes "db.local:5432".  References are absolute keys fetched with get, string values are inserted without
+// their quotes and other values as their json.  Referenced values may contain references of their own, a cycle
+// is an error.  $${ is an escaped ${ that is kept as is.  Values that are not json strings are returned unchanged.
+func Interpolate(key string, value []byte, get func(key string) ([]byte, error)) ([]byte, error) {
+	if !bytes.Contains(value, []byte("${")) {
+		return value, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		//only strings are interpolated
+		return value, nil
+	}
+
+	ret, err := interpolate(s, []string{strings.Trim(key, "/")}, get)
+	if err != nil {
+		return nil, fmt.Errorf("Could not interpolate config (%s) %v", key, err)
+	}
+	return json.Marshal(ret)
+}
+
+// interpolate expands the references in s, stack is the chain of keys being resolved
+func interpolate(s string, stack []string, get func(key string) ([]byte, error)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated reference: %s", s[i:])
+			}
+			ref := strings.Trim(strings.TrimSpace(s[i+2:i+2+end]), "/")
+			if ref == "" {
+				return "", fmt.Errorf("Empty reference: %s", s[i:i+3+end])
+			}
+			v, err := resolveReference(ref, stack, get)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i += 3 + end
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+func resolveReference(ref string, stack []string, get func(key string) ([]byte, error)) (string, error) {
+	for _, k := range stack {
+		if k == ref {
+			return "", fmt.Errorf("Reference cycle: %s -> %s", strings.Join(stack, " -> "), ref)
+		}
+	}
+
+	raw, err := get(ref)
+	if err != nil {
+		return "", fmt.Errorf("Could not resolve reference ${%s}: %v", ref, err)
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		//numbers, bools and anything else are inserted as they are stored
+		return string(bytes.TrimSpace(raw)), nil
+	}
+	return interpolate(s, append(stack[:len(stack):len(stack)], ref), get)
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	kvs := map[string][]byte{
+		"database/host": []byte(`"db.local"`),
+		"database/port": []byte(`5432`),
+		"database/tls":  []byte(`true`),
+		"database/url":  []byte(`"${database/host}:${database/port}"`),
+		"dsn":           []byte(`"postgres://${database/url}"`),
+		"cycle/a":       []byte(`"${cycle/b}"`),
+		"cycle/b":       []byte(`"${cycle/a}"`),
+		"self":          []byte(`"${self}"`),
+		"bad/ref":       []byte(`"${unterminated"`),
+	}
+	get := func(key string) ([]byte, error) {
+		v, ok := kvs[key]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return v, nil
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "no references", key: "k", value: `"plain"`, want: `"plain"`},
+		{name: "not a string", key: "k", value: `{"a":"${database/host}"}`, want: `{"a":"${database/host}"}`},
+		{name: "string reference", key: "k", value: `"${database/host}"`, want: `"db.local"`},
+		{name: "number and bool are inserted as json", key: "k", value: `"${database/port}/${database/tls}"`, want: `"5432/true"`},
+		{name: "nested references", key: "k", value: `"${dsn}"`, want: `"postgres://db.local:5432"`},
+		{name: "spaces and slashes are trimmed", key: "k", value: `"${ /database/host/ }"`, want: `"db.local"`},
+		{name: "escaped", key: "k", value: `"$${database/host}"`, want: `"${database/host}"`},
+		{name: "escaped next to a reference", key: "k", value: `"$${x}${database/host}"`, want: `"${x}db.local"`},
+		{name: "lone dollar", key: "k", value: `"$5 ${database/port}"`, want: `"$5 5432"`},
+		{name: "unterminated", key: "k", value: `"${database/host"`, wantErr: "Unterminated reference: ${database/host"},
+		{name: "unterminated in a referenced value", key: "k", value: `"${bad/ref}"`, wantErr: "Unterminated reference"},
+		{name: "empty reference", key: "k", value: `"${ }"`, wantErr: "Empty reference"},
+		{name: "missing reference", key: "k", value: `"${nope}"`, wantErr: "Could not resolve reference ${nope}"},
+		{name: "cycle", key: "cycle/a", value: `"${cycle/b}"`, wantErr: "Reference cycle: cycle/a -> cycle/b -> cycle/a"},
+		{name: "self reference", key: "/self/", value: `"${self}"`, wantErr: "Reference cycle: self -> self"},
+		{name: "cycle beneath the key", key: "k", value: `"${cycle/a}"`, wantErr: "Reference cycle: k -> cycle/a -> cycle/b -> cycle/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Interpolate(tt.key, []byte(tt.value), get)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Interpolate error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Interpolate: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Interpolate = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateSiblingsAreNotCycles(t *testing.T) {
+	//the same key referenced twice in one value is not a cycle
+	get := func(key string) ([]byte, error) { return []byte(`"x"`), nil }
+	got, err := Interpolate("k", []byte(`"${a}${a}"`), get)
+	if err != nil {
+		t.Fatalf("Interpolate: %v", err)
+	}
+	if string(got) != `"xx"` {
+		t.Fatalf("Interpolate = %s, want \"xx\"", got)
+	}
+}
@@ -1,14 +1,71 @@
 package config
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrConflict is returned (wrapped) by PutCAS when the key was changed by someone else since it was last read
+var ErrConflict = errors.New("Key was modified since it was last read")
 
 // Loader is a object that can import, initialize, and Get config values
 //go:generate go run -mod=mod github.com/golang/mock/mockgen -package loadermock -destination=./loadermock/mock_loader.go -source=../config/loader.go -build_flags=-mod=mod
 type Loader interface {
 	Import(data []byte) error
+	// Sync imports data like Import and then deletes every key absent from data
+	Sync(data []byte) error
+	// ImportPlan reports the keys Import would add or modify and the keys Sync would also delete, without writing
+	ImportPlan(data []byte) ([]ChangeEvent, error)
+	// Export returns the stored keys rebuilt into a nested json document, the reverse of Import
+	Export() ([]byte, error)
 	Initialize() error
+	// InitializeContext is Initialize that gives up once ctx is cancelled
+	InitializeContext(ctx context.Context) error
+	// Watch keeps the loader up to date with its backing store until ctx is cancelled
+	Watch(ctx context.Context) error
+	// Status reports when the data was loaded and whether it is stale
+	Status() Status
 	Get(key string) ([]byte, error)
 	Put(key string, value []byte) error
+	// PutCAS is Put that fails with ErrConflict instead of overwriting a change made since the key was last read
+	PutCAS(key string, value []byte) error
+	Delete(key string) error
+	// DeleteTree deletes prefix and every key beneath it
+	DeleteTree(prefix string) error
+	// Source reports where the value for key is read from, e.g. the consul namespace that holds it
+	Source(key string) (string, error)
+
+	// Sub returns a view of the keys beneath prefix that shares this loader's data
+	Sub(prefix string) Loader
+
+	// Unmarshal decodes every key beneath prefix into v, see UnmarshalKeys for the supported struct tags
+	Unmarshal(prefix string, v interface{}) error
+
+	// OnChange calls fn whenever keyOrPrefix or a key beneath it is added, modified or deleted.
+	// The returned func cancels the subscription.
+	OnChange(keyOrPrefix string, fn ChangeFunc) func()
+	// OnChanges calls fn once per reload with every change OnChange would have been called for
+	OnChanges(keyOrPrefix string, fn ChangesFunc) func()
+	// Subscribe delivers the same changes as OnChange over a channel
+	Subscribe(keyOrPrefix string) (<-chan ChangeEvent, func())
+
+	// Get functions return an error if the key is missing or can't be parsed into the requested type
+	GetString(key string) (string, error)
+	GetBool(key string) (bool, error)
+	GetInt(key string) (int, error)
+	GetDuration(key string) (time.Duration, error)
+	// Slice and map functions decode json arrays and objects, objects may also be stored as flattened keys
+	GetStringSlice(key string) ([]string, error)
+	GetIntSlice(key string) ([]int, error)
+	GetStringMap(key string) (map[string]string, error)
+
+	// Or functions return the given default if the key is missing or can't be parsed into the requested type.
+	// They are meant for optional configs.
+	GetStringOr(key string, def string) string
+	GetBoolOr(key string, def bool) bool
+	GetIntOr(key string, def int) int
+	GetDurationOr(key string, def time.Duration) time.Duration
 
 	// Must functions will panic if they can't do what is requested.
 	// They are maingly meant for use with configs that are required for an app to start up
@@ -16,6 +73,7 @@ type Loader interface {
 	MustGetBool(key string) bool
 	MustGetInt(key string) int
 	MustGetDuration(key string) time.Duration
-
-	//TODO add array support?
+	MustGetStringSlice(key string) []string
+	MustGetIntSlice(key string) []int
+	MustGetStringMap(key string) map[string]string
 }
@@ -5,9 +5,11 @@
 package loadermock
 
 import (
+	context "context"
 	reflect "reflect"
 	time "time"
 
+	config "github.com/divideandconquer/go-consul-client/src/config"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,6 +36,49 @@ func (m *MockLoader) EXPECT() *MockLoaderMockRecorder {
 	return m.recorder
 }
 
+// Delete mocks base method.
+func (m *MockLoader) Delete(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockLoaderMockRecorder) Delete(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockLoader)(nil).Delete), key)
+}
+
+// DeleteTree mocks base method.
+func (m *MockLoader) DeleteTree(prefix string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTree", prefix)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTree indicates an expected call of DeleteTree.
+func (mr *MockLoaderMockRecorder) DeleteTree(prefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTree", reflect.TypeOf((*MockLoader)(nil).DeleteTree), prefix)
+}
+
+// Export mocks base method.
+func (m *MockLoader) Export() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockLoaderMockRecorder) Export() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockLoader)(nil).Export))
+}
+
 // Get mocks base method.
 func (m *MockLoader) Get(key string) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +94,167 @@ func (mr *MockLoaderMockRecorder) Get(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockLoader)(nil).Get), key)
 }
 
+// GetBool mocks base method.
+func (m *MockLoader) GetBool(key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBool", key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBool indicates an expected call of GetBool.
+func (mr *MockLoaderMockRecorder) GetBool(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBool", reflect.TypeOf((*MockLoader)(nil).GetBool), key)
+}
+
+// GetBoolOr mocks base method.
+func (m *MockLoader) GetBoolOr(key string, def bool) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBoolOr", key, def)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GetBoolOr indicates an expected call of GetBoolOr.
+func (mr *MockLoaderMockRecorder) GetBoolOr(key, def interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoolOr", reflect.TypeOf((*MockLoader)(nil).GetBoolOr), key, def)
+}
+
+// GetDuration mocks base method.
+func (m *MockLoader) GetDuration(key string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDuration", key)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDuration indicates an expected call of GetDuration.
+func (mr *MockLoaderMockRecorder) GetDuration(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDuration", reflect.TypeOf((*MockLoader)(nil).GetDuration), key)
+}
+
+// GetDurationOr mocks base method.
+func (m *MockLoader) GetDurationOr(key string, def time.Duration) time.Duration {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDurationOr", key, def)
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// GetDurationOr indicates an expected call of GetDurationOr.
+func (mr *MockLoaderMockRecorder) GetDurationOr(key, def interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDurationOr", reflect.TypeOf((*MockLoader)(nil).GetDurationOr), key, def)
+}
+
+// GetInt mocks base method.
+func (m *MockLoader) GetInt(key string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInt", key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInt indicates an expected call of GetInt.
+func (mr *MockLoaderMockRecorder) GetInt(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInt", reflect.TypeOf((*MockLoader)(nil).GetInt), key)
+}
+
+// GetIntOr mocks base method.
+func (m *MockLoader) GetIntOr(key string, def int) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIntOr", key, def)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GetIntOr indicates an expected call of GetIntOr.
+func (mr *MockLoaderMockRecorder) GetIntOr(key, def interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIntOr", reflect.TypeOf((*MockLoader)(nil).GetIntOr), key, def)
+}
+
+// GetIntSlice mocks base method.
+func (m *MockLoader) GetIntSlice(key string) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIntSlice", key)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIntSlice indicates an expected call of GetIntSlice.
+func (mr *MockLoaderMockRecorder) GetIntSlice(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIntSlice", reflect.TypeOf((*MockLoader)(nil).GetIntSlice), key)
+}
+
+// GetString mocks base method.
+func (m *MockLoader) GetString(key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetString", key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetString indicates an expected call of GetString.
+func (mr *MockLoaderMockRecorder) GetString(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetString", reflect.TypeOf((*MockLoader)(nil).GetString), key)
+}
+
+// GetStringMap mocks base method.
+func (m *MockLoader) GetStringMap(key string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStringMap", key)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStringMap indicates an expected call of GetStringMap.
+func (mr *MockLoaderMockRecorder) GetStringMap(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStringMap", reflect.TypeOf((*MockLoader)(nil).GetStringMap), key)
+}
+
+// GetStringOr mocks base method.
+func (m *MockLoader) GetStringOr(key, def string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStringOr", key, def)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetStringOr indicates an expected call of GetStringOr.
+func (mr *MockLoaderMockRecorder) GetStringOr(key, def interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStringOr", reflect.TypeOf((*MockLoader)(nil).GetStringOr), key, def)
+}
+
+// GetStringSlice mocks base method.
+func (m *MockLoader) GetStringSlice(key string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStringSlice", key)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStringSlice indicates an expected call of GetStringSlice.
+func (mr *MockLoaderMockRecorder) GetStringSlice(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStringSlice", reflect.TypeOf((*MockLoader)(nil).GetStringSlice), key)
+}
+
 // Import mocks base method.
 func (m *MockLoader) Import(data []byte) error {
 	m.ctrl.T.Helper()
@@ -63,6 +269,21 @@ func (mr *MockLoaderMockRecorder) Import(data interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockLoader)(nil).Import), data)
 }
 
+// ImportPlan mocks base method.
+func (m *MockLoader) ImportPlan(data []byte) ([]config.ChangeEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportPlan", data)
+	ret0, _ := ret[0].([]config.ChangeEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportPlan indicates an expected call of ImportPlan.
+func (mr *MockLoaderMockRecorder) ImportPlan(data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportPlan", reflect.TypeOf((*MockLoader)(nil).ImportPlan), data)
+}
+
 // Initialize mocks base method.
 func (m *MockLoader) Initialize() error {
 	m.ctrl.T.Helper()
@@ -77,6 +298,20 @@ func (mr *MockLoaderMockRecorder) Initialize() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Initialize", reflect.TypeOf((*MockLoader)(nil).Initialize))
 }
 
+// InitializeContext mocks base method.
+func (m *MockLoader) InitializeContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InitializeContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InitializeContext indicates an expected call of InitializeContext.
+func (mr *MockLoaderMockRecorder) InitializeContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitializeContext", reflect.TypeOf((*MockLoader)(nil).InitializeContext), ctx)
+}
+
 // MustGetBool mocks base method.
 func (m *MockLoader) MustGetBool(key string) bool {
 	m.ctrl.T.Helper()
@@ -119,6 +354,20 @@ func (mr *MockLoaderMockRecorder) MustGetInt(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MustGetInt", reflect.TypeOf((*MockLoader)(nil).MustGetInt), key)
 }
 
+// MustGetIntSlice mocks base method.
+func (m *MockLoader) MustGetIntSlice(key string) []int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MustGetIntSlice", key)
+	ret0, _ := ret[0].([]int)
+	return ret0
+}
+
+// MustGetIntSlice indicates an expected call of MustGetIntSlice.
+func (mr *MockLoaderMockRecorder) MustGetIntSlice(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MustGetIntSlice", reflect.TypeOf((*MockLoader)(nil).MustGetIntSlice), key)
+}
+
 // MustGetString mocks base method.
 func (m *MockLoader) MustGetString(key string) string {
 	m.ctrl.T.Helper()
@@ -133,6 +382,62 @@ func (mr *MockLoaderMockRecorder) MustGetString(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MustGetString", reflect.TypeOf((*MockLoader)(nil).MustGetString), key)
 }
 
+// MustGetStringMap mocks base method.
+func (m *MockLoader) MustGetStringMap(key string) map[string]string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MustGetStringMap", key)
+	ret0, _ := ret[0].(map[string]string)
+	return ret0
+}
+
+// MustGetStringMap indicates an expected call of MustGetStringMap.
+func (mr *MockLoaderMockRecorder) MustGetStringMap(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MustGetStringMap", reflect.TypeOf((*MockLoader)(nil).MustGetStringMap), key)
+}
+
+// MustGetStringSlice mocks base method.
+func (m *MockLoader) MustGetStringSlice(key string) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MustGetStringSlice", key)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// MustGetStringSlice indicates an expected call of MustGetStringSlice.
+func (mr *MockLoaderMockRecorder) MustGetStringSlice(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MustGetStringSlice", reflect.TypeOf((*MockLoader)(nil).MustGetStringSlice), key)
+}
+
+// OnChange mocks base method.
+func (m *MockLoader) OnChange(keyOrPrefix string, fn config.ChangeFunc) func() {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OnChange", keyOrPrefix, fn)
+	ret0, _ := ret[0].(func())
+	return ret0
+}
+
+// OnChange indicates an expected call of OnChange.
+func (mr *MockLoaderMockRecorder) OnChange(keyOrPrefix, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnChange", reflect.TypeOf((*MockLoader)(nil).OnChange), keyOrPrefix, fn)
+}
+
+// OnChanges mocks base method.
+func (m *MockLoader) OnChanges(keyOrPrefix string, fn config.ChangesFunc) func() {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OnChanges", keyOrPrefix, fn)
+	ret0, _ := ret[0].(func())
+	return ret0
+}
+
+// OnChanges indicates an expected call of OnChanges.
+func (mr *MockLoaderMockRecorder) OnChanges(keyOrPrefix, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnChanges", reflect.TypeOf((*MockLoader)(nil).OnChanges), keyOrPrefix, fn)
+}
+
 // Put mocks base method.
 func (m *MockLoader) Put(key string, value []byte) error {
 	m.ctrl.T.Helper()
@@ -146,3 +451,117 @@ func (mr *MockLoaderMockRecorder) Put(key, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockLoader)(nil).Put), key, value)
 }
+
+// PutCAS mocks base method.
+func (m *MockLoader) PutCAS(key string, value []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutCAS", key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutCAS indicates an expected call of PutCAS.
+func (mr *MockLoaderMockRecorder) PutCAS(key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutCAS", reflect.TypeOf((*MockLoader)(nil).PutCAS), key, value)
+}
+
+// Source mocks base method.
+func (m *MockLoader) Source(key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Source", key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Source indicates an expected call of Source.
+func (mr *MockLoaderMockRecorder) Source(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Source", reflect.TypeOf((*MockLoader)(nil).Source), key)
+}
+
+// Status mocks base method.
+func (m *MockLoader) Status() config.Status {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status")
+	ret0, _ := ret[0].(config.Status)
+	return ret0
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockLoaderMockRecorder) Status() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockLoader)(nil).Status))
+}
+
+// Sub mocks base method.
+func (m *MockLoader) Sub(prefix string) config.Loader {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sub", prefix)
+	ret0, _ := ret[0].(config.Loader)
+	return ret0
+}
+
+// Sub indicates an expected call of Sub.
+func (mr *MockLoaderMockRecorder) Sub(prefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sub", reflect.TypeOf((*MockLoader)(nil).Sub), prefix)
+}
+
+// Subscribe mocks base method.
+func (m *MockLoader) Subscribe(keyOrPrefix string) (<-chan config.ChangeEvent, func()) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", keyOrPrefix)
+	ret0, _ := ret[0].(<-chan config.ChangeEvent)
+	ret1, _ := ret[1].(func())
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockLoaderMockRecorder) Subscribe(keyOrPrefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockLoader)(nil).Subscribe), keyOrPrefix)
+}
+
+// Sync mocks base method.
+func (m *MockLoader) Sync(data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sync", data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Sync indicates an expected call of Sync.
+func (mr *MockLoaderMockRecorder) Sync(data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sync", reflect.TypeOf((*MockLoader)(nil).Sync), data)
+}
+
+// Unmarshal mocks base method.
+func (m *MockLoader) Unmarshal(prefix string, v interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unmarshal", prefix, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unmarshal indicates an expected call of Unmarshal.
+func (mr *MockLoaderMockRecorder) Unmarshal(prefix, v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unmarshal", reflect.TypeOf((*MockLoader)(nil).Unmarshal), prefix, v)
+}
+
+// Watch mocks base method.
+func (m *MockLoader) Watch(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockLoaderMockRecorder) Watch(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockLoader)(nil).Watch), ctx)
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyType is the type a manifest expects a key to hold
+type KeyType int
+
+const (
+	// TypeString is a json string, read with GetString
+	TypeString KeyType = iota
+	// TypeInt is a json number without a fraction, read with GetInt
+	TypeInt
+	// TypeBool is a json boolean, read with GetBool
+	TypeBool
+	// TypeDuration is a json string such as "5s", read with GetDuration
+	TypeDuration
+	// TypeStringSlice is a json array of strings, read with GetStringSlice
+	TypeStringSlice
+	// TypeIntSlice is a json array of ints, read with GetIntSlice
+	TypeIntSlice
+	// TypeStringMap is a json object of strings, read with GetStringMap
+	TypeStringMap
+)
+
+func (t KeyType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeBool:
+		return "bool"
+	case TypeDuration:
+		return "duration"
+	case TypeStringSlice:
+		return "string slice"
+	case TypeIntSlice:
+		return "int slice"
+	case TypeStringMap:
+		return "string map"
+	}
+	return "unknown"
+}
+
+// Manifest declares the keys an application expects so they can all be checked once at startup
+type Manifest struct {
+	loader   Loader
+	expected []expectedKey
+}
+
+type expectedKey struct {
+	key      string
+	t        KeyType
+	optional bool
+}
+
+// KeyProblem is a single key that failed a manifest check
+type KeyProblem struct {
+	Key     string
+	Type    KeyType
+	Missing bool  // the key isn't set at all, otherwise it is set but holds the wrong type
+	Err     error // the error returned when reading the key
+}
+
+// ManifestError lists every key that failed a manifest check
+type ManifestError struct {
+	Problems []KeyProblem
+}
+
+func (e *ManifestError) Error() string {
+	var missing, invalid []string
+	for _, p := range e.Problems {
+		if p.Missing {
+			missing = append(missing, p.Key)
+		} else {
+			invalid = append(invalid, fmt.Sprintf("%s expected %s (%v)", p.Key, p.Type, p.Err))
+		}
+	}
+
+	var parts []string
+	if len(missing) > 0 {
+		parts = append(parts, "missing keys: "+strings.Join(missing, ", "))
+	}
+	if len(invalid) > 0 {
+		parts = append(parts, "invalid keys: "+strings.Join(invalid, "; "))
+	}
+	return "Config does not match its manifest, " + strings.Join(parts, ", ")
+}
+
+// NewManifest creates an empty manifest for the keys of loader
+func NewManifest(loader Loader) *Manifest {
+	return &Manifest{loader: loader}
+}
+
+// Require declares a key that must be set and hold the given type
+func (m *Manifest) Require(key string, t KeyType) *Manifest {
+	m.expected = append(m.expected, expectedKey{key: key, t: t})
+	return m
+}
+
+// Optional declares a key that may be missing but must hold the given type when it is set
+func (m *Manifest) Optional(key string, t KeyType) *Manifest {
+	m.expected = append(m.expected, expectedKey{key: key, t: t, optional: true})
+	return m
+}
+
+// Validate checks every declared key against the loader and returns a *ManifestError listing every problem
+func (m *Manifest) Validate() error {
+	result := &ManifestError{}
+	for _, e := range m.expected {
+		err := m.check(e.key, e.t)
+		if err == nil {
+			continue
+		}
+
+		missing := !m.exists(e.key)
+		if missing && e.optional {
+			continue
+		}
+		result.Problems = append(result.Problems, KeyProblem{Key: e.key, Type: e.t, Missing: missing, Err: err})
+	}
+
+	if len(result.Problems) > 0 {
+		return result
+	}
+	return nil
+}
+
+// exists reports whether anything is stored at key, either as a value or as nested keys
+func (m *Manifest) exists(key string) bool {
+	if _, err := m.loader.Get(key); err == nil {
+		return true
+	}
+	var v interface{}
+	if err := m.loader.Unmarshal(key, &v); err != nil {
+		//something is stored there even if it can't be decoded
+		return true
+	}
+	nested, ok := v.(map[string]interface{})
+	return !ok || len(nested) > 0
+}
+
+func (m *Manifest) check(key string, t KeyType) error {
+	var err error
+	switch t {
+	case TypeString:
+		_, err = m.loader.GetString(key)
+	case TypeInt:
+		_, err = m.loader.GetInt(key)
+	case TypeBool:
+		_, err = m.loader.GetBool(key)
+	case TypeDuration:
+		_, err = m.loader.GetDuration(key)
+	case TypeStringSlice:
+		_, err = m.loader.GetStringSlice(key)
+	case TypeIntSlice:
+		_, err = m.loader.GetIntSlice(key)
+	case TypeStringMap:
+		_, err = m.loader.GetStringMap(key)
+	default:
+		err = fmt.Errorf("Unknown key type %d", t)
+	}
+	return err
+}
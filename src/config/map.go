@@ -1,14 +1,19 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
 
 type mappedLoader struct {
+	Notifier
+
 	data     map[string]json.RawMessage
+	loadedAt time.Time
 	dataLock sync.RWMutex
 }
 
@@ -27,85 +32,506 @@ func (m *mappedLoader) Import(data []byte) error {
 	if err != nil {
 		return fmt.Errorf("Unable to parse json data: %v", err)
 	}
+
+	m.dataLock.Lock()
+	events := Diff(rawMap(m.data), rawMap(conf))
 	m.data = conf
+	m.loadedAt = time.Now()
+	m.dataLock.Unlock()
+
+	m.Notify(events)
+	return nil
+}
+
+// merge is Import without replacing the keys data doesn't mention, objects are merged key by key
+func (m *mappedLoader) merge(data []byte) error {
+	conf := make(map[string]json.RawMessage)
+	err := json.Unmarshal(data, &conf)
+	if err != nil {
+		return fmt.Errorf("Unable to parse json data: %v", err)
+	}
+
+	m.dataLock.Lock()
+	merged := make(map[string]json.RawMessage, len(m.data)+len(conf))
+	for k, v := range m.data {
+		merged[k] = v
+	}
+	for k, v := range conf {
+		if existing, ok := merged[k]; ok {
+			v, err = mergeJSON(existing, v)
+			if err != nil {
+				m.dataLock.Unlock()
+				return fmt.Errorf("Could not merge config (%s) %v", k, err)
+			}
+		}
+		merged[k] = v
+	}
+	events := Diff(rawMap(m.data), rawMap(merged))
+	m.data = merged
+	m.loadedAt = time.Now()
+	m.dataLock.Unlock()
+
+	m.Notify(events)
 	return nil
 }
 
+// mergeJSON merges two json objects preferring the values in new, anything other than two objects is replaced by new
+func mergeJSON(old, new json.RawMessage) (json.RawMessage, error) {
+	var o, n map[string]json.RawMessage
+	if json.Unmarshal(old, &o) != nil || json.Unmarshal(new, &n) != nil || o == nil || n == nil {
+		return new, nil
+	}
+	for k, v := range n {
+		if existing, ok := o[k]; ok {
+			merged, err := mergeJSON(existing, v)
+			if err != nil {
+				return nil, err
+			}
+			v = merged
+		}
+		o[k] = v
+	}
+	return json.Marshal(o)
+}
+
+// Sync is the same as Import as Import already replaces all of the data
+func (m *mappedLoader) Sync(data []byte) error {
+	return m.Import(data)
+}
+
+// ImportPlan reports how Import would change the data without changing it.  Like the plan of a consul
+// backed loader the events are keyed by the flattened keys, e.g. database/host, rather than by the objects
+// holding them.
+func (m *mappedLoader) ImportPlan(data []byte) ([]ChangeEvent, error) {
+	conf := make(map[string]json.RawMessage)
+	err := json.Unmarshal(data, &conf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse json data: %v", err)
+	}
+	before, err := flatten(m.raw())
+	if err != nil {
+		return nil, err
+	}
+	after, err := flatten(rawMap(conf))
+	if err != nil {
+		return nil, err
+	}
+	return Diff(before, after), nil
+}
+
+// flatten expands kvs and flattens them again so keys stored inside json objects become a/b/c keys
+func flatten(kvs map[string][]byte) (map[string][]byte, error) {
+	tree, err := Expand(kvs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte)
+	err = flattenInto(result, "", tree)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Export returns the data as a nested json document
+func (m *mappedLoader) Export() ([]byte, error) {
+	return ExportKeys(m.raw())
+}
+
 // Initialize loads the consul KV's from the namespace into cache for later retrieval
 func (m *mappedLoader) Initialize() error {
 	//noop
 	return nil
 }
 
-// Get fetches the raw config from cache
-func (m *mappedLoader) Get(key string) ([]byte, error) {
+// InitializeContext is a noop like Initialize
+func (m *mappedLoader) InitializeContext(ctx context.Context) error {
+	return nil
+}
+
+// Watch has nothing to watch for a mapped loader so it blocks until ctx is cancelled
+func (m *mappedLoader) Watch(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Status reports when the data was last imported, mapped data is never stale
+func (m *mappedLoader) Status() Status {
 	m.dataLock.RLock()
 	defer m.dataLock.RUnlock()
+	return Status{LoadedAt: m.loadedAt}
+}
+
+// Get fetches the raw config from cache.  Nested keys such as a/b are looked up inside the stored json objects
+// and references to other keys in string values are resolved, see Interpolate.
+func (m *mappedLoader) Get(key string) ([]byte, error) {
+	ret, err := m.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return Interpolate(key, ret, m.lookup)
+}
+
+// GetRaw is Get without resolving references to other keys
+func (m *mappedLoader) GetRaw(key string) ([]byte, error) {
+	return m.lookup(key)
+}
 
-	if ret, ok := m.data[key]; ok {
+// lookup is Get without interpolation
+func (m *mappedLoader) lookup(key string) ([]byte, error) {
+	m.dataLock.RLock()
+	ret, ok := m.data[key]
+	m.dataLock.RUnlock()
+	if ok {
 		return ret, nil
 	}
-	return nil, fmt.Errorf("Could not find value for key: %s", key)
+
+	node, found, err := Subtree(m.raw(), key)
+	if err != nil {
+		return nil, fmt.Errorf("Could not fetch config (%s) %v", key, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return json.Marshal(node)
 }
 
-// MustGetString fetches the config and parses it into a string.  Panics on failure.
-func (m *mappedLoader) MustGetString(key string) string {
-	b, err := m.Get(key)
+// Source returns an empty source for every key that exists as a mapped loader has a single source
+func (m *mappedLoader) Source(key string) (string, error) {
+	_, err := m.lookup(key)
+	return "", err
+}
+
+// Sub returns a loader rooted at prefix that reads from this loader's data
+func (m *mappedLoader) Sub(prefix string) Loader {
+	return Scope(m, prefix)
+}
+
+// Unmarshal decodes the keys beneath prefix into v
+func (m *mappedLoader) Unmarshal(prefix string, v interface{}) error {
+	return UnmarshalKeys(m.raw(), prefix, v)
+}
+
+// raw copies the data so it can be decoded without holding the lock
+func (m *mappedLoader) raw() map[string][]byte {
+	m.dataLock.RLock()
+	defer m.dataLock.RUnlock()
+	return rawMap(m.data)
+}
+
+// GetString fetches the config and parses it into a string
+func (m *mappedLoader) GetString(key string) (string, error) {
+	return getString(m.Get, key)
+}
+
+// GetBool fetches the config and parses it into a bool
+func (m *mappedLoader) GetBool(key string) (bool, error) {
+	return getBool(m.Get, key)
+}
+
+// GetInt fetches the config and parses it into an int
+func (m *mappedLoader) GetInt(key string) (int, error) {
+	return getInt(m.Get, key)
+}
+
+// GetDuration fetches the config and parses it into a duration
+func (m *mappedLoader) GetDuration(key string) (time.Duration, error) {
+	return getDuration(m.Get, key)
+}
+
+// GetStringSlice fetches the config and parses it into a slice of strings
+func (m *mappedLoader) GetStringSlice(key string) ([]string, error) {
+	var ret []string
+	err := DecodeKey(m.raw(), key, &ret)
 	if err != nil {
-		panic(fmt.Sprintf("Could not fetch config (%s) %v", key, err))
+		return nil, err
 	}
+	return ret, nil
+}
 
-	var s string
-	err = json.Unmarshal(b, &s)
+// GetIntSlice fetches the config and parses it into a slice of ints
+func (m *mappedLoader) GetIntSlice(key string) ([]int, error) {
+	var ret []int
+	err := DecodeKey(m.raw(), key, &ret)
 	if err != nil {
-		panic(fmt.Sprintf("Could not unmarshal config (%s) %v", key, err))
+		return nil, err
 	}
+	return ret, nil
+}
 
+// GetStringMap fetches the config and parses it into a map of strings
+func (m *mappedLoader) GetStringMap(key string) (map[string]string, error) {
+	var ret map[string]string
+	err := DecodeKey(m.raw(), key, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetStringOr fetches the config and parses it into a string.  Returns def on failure.
+func (m *mappedLoader) GetStringOr(key string, def string) string {
+	s, err := m.GetString(key)
+	if err != nil {
+		return def
+	}
 	return s
 }
 
-// MustGetBool fetches the config and parses it into a bool.  Panics on failure.
-func (m *mappedLoader) MustGetBool(key string) bool {
-	b, err := m.Get(key)
+// GetBoolOr fetches the config and parses it into a bool.  Returns def on failure.
+func (m *mappedLoader) GetBoolOr(key string, def bool) bool {
+	ret, err := m.GetBool(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not fetch config (%s) %v", key, err))
+		return def
 	}
-	var ret bool
-	err = json.Unmarshal(b, &ret)
+	return ret
+}
+
+// GetIntOr fetches the config and parses it into an int.  Returns def on failure.
+func (m *mappedLoader) GetIntOr(key string, def int) int {
+	ret, err := m.GetInt(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not unmarshal config (%s) %v", key, err))
+		return def
 	}
 	return ret
 }
 
-// MustGetInt fetches the config and parses it into an int.  Panics on failure.
-func (m *mappedLoader) MustGetInt(key string) int {
-	b, err := m.Get(key)
+// GetDurationOr fetches the config and parses it into a duration.  Returns def on failure.
+func (m *mappedLoader) GetDurationOr(key string, def time.Duration) time.Duration {
+	ret, err := m.GetDuration(key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+// MustGetString fetches the config and parses it into a string.  Panics on failure.
+func (m *mappedLoader) MustGetString(key string) string {
+	s, err := m.GetString(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not fetch config (%s) %v", key, err))
+		panic(err.Error())
 	}
+	return s
+}
+
+// MustGetBool fetches the config and parses it into a bool.  Panics on failure.
+func (m *mappedLoader) MustGetBool(key string) bool {
+	ret, err := m.GetBool(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
 
-	var ret int
-	err = json.Unmarshal(b, &ret)
+// MustGetInt fetches the config and parses it into an int.  Panics on failure.
+func (m *mappedLoader) MustGetInt(key string) int {
+	ret, err := m.GetInt(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not unmarshal config (%s) %v", key, err))
+		panic(err.Error())
 	}
 	return ret
 }
 
 // MustGetDuration fetches the config and parses it into a duration.  Panics on failure.
 func (m *mappedLoader) MustGetDuration(key string) time.Duration {
-	s := m.MustGetString(key)
-	ret, err := time.ParseDuration(s)
+	ret, err := m.GetDuration(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// MustGetStringSlice fetches the config and parses it into a slice of strings.  Panics on failure.
+func (m *mappedLoader) MustGetStringSlice(key string) []string {
+	ret, err := m.GetStringSlice(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// MustGetIntSlice fetches the config and parses it into a slice of ints.  Panics on failure.
+func (m *mappedLoader) MustGetIntSlice(key string) []int {
+	ret, err := m.GetIntSlice(key)
 	if err != nil {
-		panic(fmt.Sprintf("Could not parse config (%s) into a duration: %v", key, err))
+		panic(err.Error())
 	}
 	return ret
 }
 
+// MustGetStringMap fetches the config and parses it into a map of strings.  Panics on failure.
+func (m *mappedLoader) MustGetStringMap(key string) map[string]string {
+	ret, err := m.GetStringMap(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// Put stores value at key.  A key beneath a stored json object, e.g. database/host when database holds
+// {"host": "h"}, is written into that object rather than stored next to it where it would be defined twice.
 func (m *mappedLoader) Put(key string, value []byte) error {
+	key = strings.Trim(key, "/")
+	old, err := m.lookup(key)
+	existed := err == nil
+
 	m.dataLock.Lock()
-	defer m.dataLock.Unlock()
-	m.data[key] = value
+	err = m.putLocked(key, value)
+	m.dataLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("Could not write config (%s) %v", key, err)
+	}
+
+	e := ChangeEvent{Key: key, Type: KeyAdded, New: value}
+	if existed {
+		e = ChangeEvent{Key: key, Type: KeyModified, Old: old, New: value}
+	}
+	m.Notify([]ChangeEvent{e})
 	return nil
 }
+
+func (m *mappedLoader) putLocked(key string, value []byte) error {
+	if m.data == nil {
+		m.data = make(map[string]json.RawMessage)
+	}
+	parent, path, ok := m.parentLocked(key)
+	if _, stored := m.data[key]; stored || !ok {
+		m.data[key] = value
+		return nil
+	}
+
+	if !json.Valid(value) {
+		//values that aren't json are read back as strings, see Expand
+		quoted, err := json.Marshal(string(value))
+		if err != nil {
+			return err
+		}
+		value = quoted
+	}
+	updated, err := setJSON(m.data[parent], parent, path, value)
+	if err != nil {
+		return err
+	}
+	m.data[parent] = updated
+	return nil
+}
+
+// setJSON sets path inside the json object raw stored at key to value, creating the objects along the way
+func setJSON(raw json.RawMessage, key string, path []string, value json.RawMessage) (json.RawMessage, error) {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) != nil || obj == nil {
+		return nil, fmt.Errorf("%s holds a value and cannot also hold nested keys", key)
+	}
+
+	if len(path) > 1 {
+		existing, ok := obj[path[0]]
+		if !ok {
+			existing = json.RawMessage("{}")
+		}
+		var err error
+		value, err = setJSON(existing, key+"/"+path[0], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	obj[path[0]] = value
+	return json.Marshal(obj)
+}
+
+// Delete removes the key from the data, including a key stored inside a json object such as database/host
+// in {"database": {"host": "h"}}
+func (m *mappedLoader) Delete(key string) error {
+	key = strings.Trim(key, "/")
+	return m.deleteMatching(key, func(k string) bool { return k == key })
+}
+
+// DeleteTree removes prefix and every key beneath it from the data
+func (m *mappedLoader) DeleteTree(prefix string) error {
+	prefix = strings.Trim(prefix, "/")
+	return m.deleteMatching(prefix, func(k string) bool { return matchesPrefix(k, prefix) })
+}
+
+// deleteMatching deletes the stored keys that match and removes key from inside the object of a stored key
+// that holds it
+func (m *mappedLoader) deleteMatching(key string, match func(key string) bool) error {
+	var events []ChangeEvent
+	m.dataLock.Lock()
+	for k, v := range m.data {
+		if match(k) {
+			delete(m.data, k)
+			events = append(events, ChangeEvent{Key: k, Type: KeyDeleted, Old: v})
+		}
+	}
+	if parent, path, ok := m.parentLocked(key); ok {
+		updated, removed, err := deleteJSON(m.data[parent], path)
+		if err != nil {
+			m.dataLock.Unlock()
+			return fmt.Errorf("Could not delete config (%s) %v", key, err)
+		}
+		if removed != nil {
+			m.data[parent] = updated
+			events = append(events, ChangeEvent{Key: key, Type: KeyDeleted, Old: removed})
+		}
+	}
+	m.dataLock.Unlock()
+
+	m.Notify(events)
+	return nil
+}
+
+// parentLocked finds the stored key whose json object holds key and the path to key inside it
+func (m *mappedLoader) parentLocked(key string) (string, []string, bool) {
+	segments := strings.Split(key, "/")
+	//prefer the longest stored key as a/b may be stored next to a
+	for i := len(segments) - 1; i > 0; i-- {
+		parent := strings.Join(segments[:i], "/")
+		if _, ok := m.data[parent]; ok {
+			return parent, segments[i:], true
+		}
+	}
+	return "", nil, false
+}
+
+// deleteJSON removes path from the json object raw and returns the updated object along with the value removed,
+// which is nil when nothing is stored at path
+func deleteJSON(raw json.RawMessage, path []string) (json.RawMessage, json.RawMessage, error) {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) != nil || obj == nil {
+		return raw, nil, nil
+	}
+	existing, ok := obj[path[0]]
+	if !ok {
+		return raw, nil, nil
+	}
+
+	removed := existing
+	if len(path) > 1 {
+		var err error
+		existing, removed, err = deleteJSON(existing, path[1:])
+		if err != nil || removed == nil {
+			return raw, removed, err
+		}
+		obj[path[0]] = existing
+	} else {
+		delete(obj, path[0])
+	}
+
+	updated, err := json.Marshal(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return updated, removed, nil
+}
+
+// PutCAS is the same as Put as a mapped loader has no other writers to conflict with
+func (m *mappedLoader) PutCAS(key string, value []byte) error {
+	return m.Put(key, value)
+}
+
+func rawMap(data map[string]json.RawMessage) map[string][]byte {
+	result := make(map[string][]byte, len(data))
+	for k, v := range data {
+		result[k] = v
+	}
+	return result
+}
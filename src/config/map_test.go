@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mappedTestDoc = `{"database": {"host": "h", "port": 5432, "tls": {"ca": "ca.pem"}}, "app/name": "a", "debug": true}`
+
+func newMappedTestLoader(t *testing.T) Loader {
+	t.Helper()
+	m, err := NewMappedLoader([]byte(mappedTestDoc))
+	if err != nil {
+		t.Fatalf("NewMappedLoader: %v", err)
+	}
+	return m
+}
+
+func TestMappedDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		delete   func(m Loader) error
+		wantGone []string
+		wantKept []string
+		wantKey  string
+	}{
+		{name: "stored key", delete: func(m Loader) error { return m.Delete("debug") }, wantGone: []string{"debug"}, wantKept: []string{"database/host", "app/name"}, wantKey: "debug"},
+		{name: "flat stored key", delete: func(m Loader) error { return m.Delete("/app/name/") }, wantGone: []string{"app/name"}, wantKept: []string{"debug"}, wantKey: "app/name"},
+		{name: "key inside an object", delete: func(m Loader) error { return m.Delete("database/host") }, wantGone: []string{"database/host"}, wantKept: []string{"database/port", "database/tls/ca"}, wantKey: "database/host"},
+		{name: "key deep inside an object", delete: func(m Loader) error { return m.Delete("database/tls/ca") }, wantGone: []string{"database/tls/ca"}, wantKept: []string{"database/host", "database/tls"}, wantKey: "database/tls/ca"},
+		{name: "tree inside an object", delete: func(m Loader) error { return m.DeleteTree("database/tls") }, wantGone: []string{"database/tls", "database/tls/ca"}, wantKept: []string{"database/host"}, wantKey: "database/tls"},
+		{name: "tree of stored keys", delete: func(m Loader) error { return m.DeleteTree("database") }, wantGone: []string{"database", "database/host"}, wantKept: []string{"app/name", "debug"}, wantKey: "database"},
+		{name: "missing key", delete: func(m Loader) error { return m.Delete("database/user") }, wantKept: []string{"database/host", "database/port"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMappedTestLoader(t)
+			var deleted []string
+			m.OnChange("", func(key string, old, new []byte) { deleted = append(deleted, key) })
+
+			if err := tt.delete(m); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			for _, k := range tt.wantGone {
+				if _, err := m.Get(k); !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get(%s) error = %v, want ErrNotFound", k, err)
+				}
+			}
+			for _, k := range tt.wantKept {
+				if _, err := m.Get(k); err != nil {
+					t.Errorf("Get(%s): %v", k, err)
+				}
+			}
+			if tt.wantKey != "" && !reflect.DeepEqual(deleted, []string{tt.wantKey}) {
+				t.Errorf("notified %v, want %s", deleted, tt.wantKey)
+			}
+			if tt.wantKey == "" && len(deleted) != 0 {
+				t.Errorf("notified %v for a missing key", deleted)
+			}
+			if _, err := m.Export(); err != nil {
+				t.Errorf("Export: %v", err)
+			}
+		})
+	}
+}
+
+func TestMappedImportPlan(t *testing.T) {
+	m := newMappedTestLoader(t)
+	plan, err := m.ImportPlan([]byte(`{"database": {"host": "h2", "tls": {"ca": "ca.pem"}}, "app": {"name": "a"}, "cors": ["*"]}`))
+	if err != nil {
+		t.Fatalf("ImportPlan: %v", err)
+	}
+	want := []ChangeEvent{
+		{Key: "cors", Type: KeyAdded, New: []byte(`["*"]`)},
+		{Key: "database/host", Type: KeyModified, Old: []byte(`"h"`), New: []byte(`"h2"`)},
+		{Key: "database/port", Type: KeyDeleted, Old: []byte(`5432`)},
+		{Key: "debug", Type: KeyDeleted, Old: []byte(`true`)},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Fatalf("ImportPlan = %+v, want %+v", plan, want)
+	}
+}
+
+func TestScopedSyncOnMappedParent(t *testing.T) {
+	m := newMappedTestLoader(t)
+	if err := Scope(m, "database").Sync([]byte(`{"host": "h2", "tls": {"ca": "ca.pem"}}`)); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if got := m.MustGetString("database/host"); got != "h2" {
+		t.Errorf("database/host = %s, want h2", got)
+	}
+	if _, err := m.Get("database/port"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(database/port) error = %v, want it pruned", err)
+	}
+	for _, k := range []string{"database/tls/ca", "app/name", "debug"} {
+		if _, err := m.Get(k); err != nil {
+			t.Errorf("Get(%s): %v", k, err)
+		}
+	}
+}
+
+func TestMappedPut(t *testing.T) {
+	tests := []struct {
+		name    string
+		loader  func(m Loader) Loader
+		key     string
+		value   string
+		getKey  string
+		want    string
+		wantErr string
+	}{
+		{name: "new top level key", loader: func(m Loader) Loader { return m }, key: "cors", value: `["*"]`, getKey: "cors", want: `["*"]`},
+		{name: "stored key", loader: func(m Loader) Loader { return m }, key: "debug", value: `false`, getKey: "debug", want: `false`},
+		{name: "flat stored key", loader: func(m Loader) Loader { return m }, key: "app/name", value: `"b"`, getKey: "app/name", want: `"b"`},
+		{name: "key inside an object", loader: func(m Loader) Loader { return m }, key: "database/host", value: `"h2"`, getKey: "database/host", want: `"h2"`},
+		{name: "through a sub loader", loader: func(m Loader) Loader { return m.Sub("database") }, key: "host", value: `"h2"`, getKey: "database/host", want: `"h2"`},
+		{name: "new key deep inside an object", loader: func(m Loader) Loader { return m.Sub("database/tls") }, key: "client/cert", value: `"c.pem"`, getKey: "database/tls/client/cert", want: `"c.pem"`},
+		{name: "object replaces an object", loader: func(m Loader) Loader { return m }, key: "database/tls", value: `{"verify":true}`, getKey: "database/tls", want: `{"verify":true}`},
+		{name: "not json", loader: func(m Loader) Loader { return m }, key: "database/user", value: `app`, getKey: "database/user", want: `"app"`},
+		{name: "beneath a value", loader: func(m Loader) Loader { return m }, key: "database/port/x", value: `1`, wantErr: "database/port holds a value and cannot also hold nested keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMappedTestLoader(t)
+			err := tt.loader(m).Put(tt.key, []byte(tt.value))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Put error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			got, err := m.Get(tt.getKey)
+			if err != nil {
+				t.Fatalf("Get(%s): %v", tt.getKey, err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Get(%s) = %s, want %s", tt.getKey, got, tt.want)
+			}
+			//the rest of the document must still decode
+			if _, err := m.Export(); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			var db struct {
+				Host string `consul:"host"`
+				Port int    `consul:"port"`
+			}
+			if err := m.Unmarshal("database", &db); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// subscriptionBuffer is how many events a Subscribe channel holds before delivery blocks
+const subscriptionBuffer = 64
+
+// ChangeType describes what happened to a key between two versions of the config
+type ChangeType int
+
+const (
+	// KeyAdded means the key did not exist before
+	KeyAdded ChangeType = iota
+	// KeyModified means the key exists in both versions with different values
+	KeyModified
+	// KeyDeleted means the key no longer exists
+	KeyDeleted
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case KeyAdded:
+		return "added"
+	case KeyModified:
+		return "modified"
+	case KeyDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// ChangeEvent describes a change to a single key.  Old is nil for added keys and New is nil for deleted keys.
+type ChangeEvent struct {
+	Key  string
+	Type ChangeType
+	Old  []byte
+	New  []byte
+}
+
+// ChangeFunc is called with the previous and current raw value of a changed key
+type ChangeFunc func(key string, old, new []byte)
+
+// ChangesFunc is called once per reload with every matching change it made
+type ChangesFunc func(events []ChangeEvent)
+
+// Diff compares two versions of a config and returns an event per added, modified or deleted key sorted by key
+func Diff(old, new map[string][]byte) []ChangeEvent {
+	var events []ChangeEvent
+	for k, n := range new {
+		o, ok := old[k]
+		if !ok {
+			events = append(events, ChangeEvent{Key: k, Type: KeyAdded, New: n})
+		} else if !bytes.Equal(o, n) {
+			events = append(events, ChangeEvent{Key: k, Type: KeyModified, Old: o, New: n})
+		}
+	}
+	for k, o := range old {
+		if _, ok := new[k]; !ok {
+			events = append(events, ChangeEvent{Key: k, Type: KeyDeleted, Old: o})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Key < events[j].Key })
+	return events
+}
+
+// Notifier keeps track of change subscriptions and delivers events to them.
+// Loader implementations embed it and call Notify after their data changes, outside of any of their own locks.
+type Notifier struct {
+	lock   sync.Mutex
+	nextID int
+	subs   map[int]subscription
+}
+
+type subscription struct {
+	prefix  string
+	deliver func([]ChangeEvent)
+}
+
+// OnChange registers fn to be called for every change to keyOrPrefix or any key beneath it.
+// An empty prefix matches every key.  The returned func removes the subscription.
+func (n *Notifier) OnChange(keyOrPrefix string, fn ChangeFunc) func() {
+	return n.subscribe(keyOrPrefix, func(events []ChangeEvent) {
+		for _, e := range events {
+			fn(e.Key, e.Old, e.New)
+		}
+	})
+}
+
+// OnChanges is OnChange for subscribers that only need to react once however many keys a reload changed,
+// fn is called once per reload with every change to keyOrPrefix or any key beneath it.
+func (n *Notifier) OnChanges(keyOrPrefix string, fn ChangesFunc) func() {
+	return n.subscribe(keyOrPrefix, fn)
+}
+
+func (n *Notifier) subscribe(keyOrPrefix string, deliver func([]ChangeEvent)) func() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.subs == nil {
+		n.subs = make(map[int]subscription)
+	}
+	id := n.nextID
+	n.nextID++
+	n.subs[id] = subscription{prefix: strings.Trim(keyOrPrefix, "/"), deliver: deliver}
+
+	return func() {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		delete(n.subs, id)
+	}
+}
+
+// Subscribe is the channel based version of OnChange.  Events are delivered in order and delivery blocks
+// once the channel buffer is full, so subscribers should keep draining it.
+// The returned func removes the subscription and closes the channel.
+func (n *Notifier) Subscribe(keyOrPrefix string) (<-chan ChangeEvent, func()) {
+	ch := make(chan ChangeEvent, subscriptionBuffer)
+	done := make(chan struct{})
+	var sendLock sync.Mutex
+
+	unsubscribe := n.subscribe(keyOrPrefix, func(events []ChangeEvent) {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		for _, e := range events {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			select {
+			case ch <- e:
+			case <-done:
+				return
+			}
+		}
+	})
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			unsubscribe()
+			close(done)
+			//wait for any in flight send to give up before closing
+			sendLock.Lock()
+			defer sendLock.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Notify delivers the events to every matching subscription, each subscription gets its events in one batch
+func (n *Notifier) Notify(events []ChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	//copy the subscriptions so callbacks are free to subscribe or unsubscribe
+	n.lock.Lock()
+	ids := make([]int, 0, len(n.subs))
+	for id := range n.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	subs := make([]subscription, 0, len(ids))
+	for _, id := range ids {
+		subs = append(subs, n.subs[id])
+	}
+	n.lock.Unlock()
+
+	for _, s := range subs {
+		var matched []ChangeEvent
+		for _, e := range events {
+			if matchesPrefix(e.Key, s.prefix) {
+				matched = append(matched, e)
+			}
+		}
+		if len(matched) > 0 {
+			s.deliver(matched)
+		}
+	}
+}
+
+// matchesPrefix reports whether key is prefix or lives beneath it
+func matchesPrefix(key, prefix string) bool {
+	return prefix == "" || key == prefix || strings.HasPrefix(key, prefix+"/")
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  map[string][]byte
+		new  map[string][]byte
+		want []ChangeEvent
+	}{
+		{name: "both empty", old: nil, new: map[string][]byte{}, want: nil},
+		{name: "unchanged", old: map[string][]byte{"a": []byte(`1`)}, new: map[string][]byte{"a": []byte(`1`)}, want: nil},
+		{
+			name: "added",
+			old:  nil,
+			new:  map[string][]byte{"b": []byte(`2`), "a": []byte(`1`)},
+			want: []ChangeEvent{
+				{Key: "a", Type: KeyAdded, New: []byte(`1`)},
+				{Key: "b", Type: KeyAdded, New: []byte(`2`)},
+			},
+		},
+		{
+			name: "deleted",
+			old:  map[string][]byte{"a": []byte(`1`)},
+			new:  nil,
+			want: []ChangeEvent{{Key: "a", Type: KeyDeleted, Old: []byte(`1`)}},
+		},
+		{
+			name: "modified",
+			old:  map[string][]byte{"a": []byte(`1`)},
+			new:  map[string][]byte{"a": []byte(`2`)},
+			want: []ChangeEvent{{Key: "a", Type: KeyModified, Old: []byte(`1`), New: []byte(`2`)}},
+		},
+		{
+			name: "mixed changes are sorted by key",
+			old:  map[string][]byte{"c": []byte(`3`), "b": []byte(`2`), "a": []byte(`1`)},
+			new:  map[string][]byte{"d": []byte(`4`), "b": []byte(`20`), "a": []byte(`1`)},
+			want: []ChangeEvent{
+				{Key: "b", Type: KeyModified, Old: []byte(`2`), New: []byte(`20`)},
+				{Key: "c", Type: KeyDeleted, Old: []byte(`3`)},
+				{Key: "d", Type: KeyAdded, New: []byte(`4`)},
+			},
+		},
+		{
+			name: "empty value is not a missing key",
+			old:  map[string][]byte{"a": []byte(``)},
+			new:  map[string][]byte{"a": nil},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	events := []ChangeEvent{
+		{Key: "database/host", Type: KeyModified},
+		{Key: "database/port", Type: KeyAdded},
+		{Key: "databases", Type: KeyAdded},
+		{Key: "features/new-ui", Type: KeyDeleted},
+	}
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{name: "everything", prefix: "", want: []string{"database/host", "database/port", "databases", "features/new-ui"}},
+		{name: "prefix", prefix: "/database/", want: []string{"database/host", "database/port"}},
+		{name: "single key", prefix: "databases", want: []string{"databases"}},
+		{name: "no match", prefix: "cors", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n Notifier
+			var batches [][]string
+			n.OnChanges(tt.prefix, func(events []ChangeEvent) {
+				var keys []string
+				for _, e := range events {
+					keys = append(keys, e.Key)
+				}
+				batches = append(batches, keys)
+			})
+			var each []string
+			unsubscribe := n.OnChange(tt.prefix, func(key string, old, new []byte) { each = append(each, key) })
+
+			n.Notify(events)
+			if tt.want == nil {
+				if len(batches) != 0 || len(each) != 0 {
+					t.Fatalf("delivered %v and %v, want nothing", batches, each)
+				}
+			} else {
+				if !reflect.DeepEqual(batches, [][]string{tt.want}) {
+					t.Fatalf("OnChanges got %v, want one batch of %v", batches, tt.want)
+				}
+				if !reflect.DeepEqual(each, tt.want) {
+					t.Fatalf("OnChange got %v, want %v", each, tt.want)
+				}
+			}
+
+			unsubscribe()
+			each = nil
+			n.Notify(events)
+			if len(each) != 0 {
+				t.Fatalf("OnChange got %v after unsubscribing", each)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaURL is the name the schema is compiled under, references to other files are not supported
+const schemaURL = "config.schema.json"
+
+// Schema is a compiled JSON Schema that config documents are validated against
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Violation is a single way a document fails its schema, Path is the key of the offending value e.g. database/port
+type Violation struct {
+	Path    string
+	Message string
+}
+
+// SchemaError lists every violation found in a document
+type SchemaError struct {
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	lines := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		lines = append(lines, v.Path+": "+v.Message)
+	}
+	return fmt.Sprintf("Config does not match its schema: %s", strings.Join(lines, "; "))
+}
+
+// CompileSchema compiles a JSON Schema document
+func CompileSchema(data []byte) (*Schema, error) {
+	c := jsonschema.NewCompiler()
+	err := c.AddResource(schemaURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse schema: %v", err)
+	}
+	s, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("Could not compile schema: %v", err)
+	}
+	return &Schema{schema: s}, nil
+}
+
+// LoadSchema reads and compiles a JSON Schema file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read schema (%s) %v", path, err)
+	}
+	return CompileSchema(data)
+}
+
+// Validate checks a nested json document, the same document Import takes, against the schema.
+// A document that doesn't match returns a *SchemaError.
+func (s *Schema) Validate(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return fmt.Errorf("Unable to parse json data: %v", err)
+	}
+	return s.validate(doc)
+}
+
+// ValidateKeys expands flattened keys such as a/b/c and checks the resulting document against the schema
+func (s *Schema) ValidateKeys(kvs map[string][]byte) error {
+	doc, err := Expand(kvs)
+	if err != nil {
+		return err
+	}
+	return s.validate(doc)
+}
+
+func (s *Schema) validate(doc interface{}) error {
+	err := s.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return fmt.Errorf("Could not validate config: %v", err)
+	}
+
+	result := &SchemaError{}
+	collectViolations(ve, result)
+	sort.SliceStable(result.Violations, func(i, j int) bool { return result.Violations[i].Path < result.Violations[j].Path })
+	return result
+}
+
+// collectViolations gathers the innermost causes, the outer errors only say that a subschema failed
+func collectViolations(ve *jsonschema.ValidationError, result *SchemaError) {
+	if len(ve.Causes) == 0 {
+		path := strings.Trim(ve.InstanceLocation, "/")
+		if path == "" {
+			path = "(root)"
+		}
+		result.Violations = append(result.Violations, Violation{Path: path, Message: ve.Message})
+		return
+	}
+	for _, c := range ve.Causes {
+		collectViolations(c, result)
+	}
+}
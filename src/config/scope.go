@@ -0,0 +1,313 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type scopedLoader struct {
+	parent Loader
+	prefix string
+}
+
+// Scope returns a Loader that reads and writes the keys of parent beneath prefix, so Scope(conf, "database")
+// resolves "host" to "database/host".  The scoped loader shares the parent's data and its reloading, so
+// Initialize and Watch are left to whoever owns the parent.
+func Scope(parent Loader, prefix string) Loader {
+	return &scopedLoader{parent: parent, prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *scopedLoader) qualify(key string) string {
+	key = strings.Trim(key, "/")
+	if key == "" {
+		return s.prefix
+	}
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+func (s *scopedLoader) relative(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, s.prefix), "/")
+}
+
+// merger is implemented by loaders whose Import replaces all of their data, merge imports on top of it instead
+type merger interface {
+	merge(data []byte) error
+}
+
+// importMerged imports data into l without replacing the keys data doesn't mention
+func importMerged(l Loader, data []byte) error {
+	if m, ok := l.(merger); ok {
+		return m.merge(data)
+	}
+	return l.Import(data)
+}
+
+// Import nests the json document beneath the prefix before importing it into the parent, keys outside of the
+// prefix are left alone
+func (s *scopedLoader) Import(data []byte) error {
+	nested, err := s.nest(data)
+	if err != nil {
+		return err
+	}
+	return importMerged(s.parent, nested)
+}
+
+// Sync imports the json document beneath the prefix and then deletes the keys beneath the prefix that are
+// absent from it.  Keys outside of the prefix are left alone.
+func (s *scopedLoader) Sync(data []byte) error {
+	plan, err := s.ImportPlan(data)
+	if err != nil {
+		return err
+	}
+
+	err = s.Import(data)
+	if err != nil {
+		return err
+	}
+	for _, e := range plan {
+		if e.Type == KeyDeleted {
+			if err := s.Delete(e.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ImportPlan reports how importing the json document beneath the prefix would change the keys beneath the prefix
+func (s *scopedLoader) ImportPlan(data []byte) ([]ChangeEvent, error) {
+	nested, err := s.nest(data)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.parent.ImportPlan(nested)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ChangeEvent
+	for _, e := range plan {
+		if matchesPrefix(e.Key, s.prefix) {
+			e.Key = s.relative(e.Key)
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// Export returns the part of the parent's document beneath the prefix
+func (s *scopedLoader) Export() ([]byte, error) {
+	data, err := s.parent.Export()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeValue(data)
+	if err != nil {
+		return nil, err
+	}
+
+	node := doc
+	if s.prefix != "" {
+		node = map[string]interface{}{}
+		if tree, ok := doc.(map[string]interface{}); ok {
+			if found, ok := lookupPath(tree, strings.Split(s.prefix, "/")); ok {
+				node = found
+			}
+		}
+	}
+	return encodeDocument(node)
+}
+
+func (s *scopedLoader) nest(data []byte) ([]byte, error) {
+	var conf interface{}
+	err := json.Unmarshal(data, &conf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse json data: %v", err)
+	}
+
+	segments := strings.Split(s.prefix, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] != "" {
+			conf = map[string]interface{}{segments[i]: conf}
+		}
+	}
+	nested, err := json.Marshal(conf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to scope json data: %v", err)
+	}
+	return nested, nil
+}
+
+// Initialize is a noop, the parent owns loading the data
+func (s *scopedLoader) Initialize() error {
+	return nil
+}
+
+// InitializeContext is a noop, the parent owns loading the data
+func (s *scopedLoader) InitializeContext(ctx context.Context) error {
+	return nil
+}
+
+// Watch blocks until ctx is cancelled, the parent owns reloading the data
+func (s *scopedLoader) Watch(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *scopedLoader) Status() Status {
+	return s.parent.Status()
+}
+
+func (s *scopedLoader) Get(key string) ([]byte, error) {
+	return s.parent.Get(s.qualify(key))
+}
+
+func (s *scopedLoader) Put(key string, value []byte) error {
+	return s.parent.Put(s.qualify(key), value)
+}
+
+func (s *scopedLoader) PutCAS(key string, value []byte) error {
+	return s.parent.PutCAS(s.qualify(key), value)
+}
+
+func (s *scopedLoader) Delete(key string) error {
+	return s.parent.Delete(s.qualify(key))
+}
+
+func (s *scopedLoader) DeleteTree(prefix string) error {
+	return s.parent.DeleteTree(s.qualify(prefix))
+}
+
+func (s *scopedLoader) Source(key string) (string, error) {
+	return s.parent.Source(s.qualify(key))
+}
+
+func (s *scopedLoader) Sub(prefix string) Loader {
+	return Scope(s.parent, s.qualify(prefix))
+}
+
+func (s *scopedLoader) Unmarshal(prefix string, v interface{}) error {
+	return s.parent.Unmarshal(s.qualify(prefix), v)
+}
+
+func (s *scopedLoader) OnChange(keyOrPrefix string, fn ChangeFunc) func() {
+	return s.parent.OnChange(s.qualify(keyOrPrefix), func(key string, old, new []byte) {
+		fn(s.relative(key), old, new)
+	})
+}
+
+func (s *scopedLoader) OnChanges(keyOrPrefix string, fn ChangesFunc) func() {
+	return s.parent.OnChanges(s.qualify(keyOrPrefix), func(events []ChangeEvent) {
+		relative := make([]ChangeEvent, len(events))
+		for i, e := range events {
+			e.Key = s.relative(e.Key)
+			relative[i] = e
+		}
+		fn(relative)
+	})
+}
+
+func (s *scopedLoader) Subscribe(keyOrPrefix string) (<-chan ChangeEvent, func()) {
+	events, unsubscribe := s.parent.Subscribe(s.qualify(keyOrPrefix))
+	ch := make(chan ChangeEvent, subscriptionBuffer)
+	done := make(chan struct{})
+
+	//relay the parent's events with the prefix stripped from their keys
+	go func() {
+		defer close(ch)
+		for e := range events {
+			e.Key = s.relative(e.Key)
+			select {
+			case ch <- e:
+			case <-done:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+}
+
+func (s *scopedLoader) GetString(key string) (string, error) {
+	return s.parent.GetString(s.qualify(key))
+}
+
+func (s *scopedLoader) GetBool(key string) (bool, error) {
+	return s.parent.GetBool(s.qualify(key))
+}
+
+func (s *scopedLoader) GetInt(key string) (int, error) {
+	return s.parent.GetInt(s.qualify(key))
+}
+
+func (s *scopedLoader) GetDuration(key string) (time.Duration, error) {
+	return s.parent.GetDuration(s.qualify(key))
+}
+
+func (s *scopedLoader) GetStringSlice(key string) ([]string, error) {
+	return s.parent.GetStringSlice(s.qualify(key))
+}
+
+func (s *scopedLoader) GetIntSlice(key string) ([]int, error) {
+	return s.parent.GetIntSlice(s.qualify(key))
+}
+
+func (s *scopedLoader) GetStringMap(key string) (map[string]string, error) {
+	return s.parent.GetStringMap(s.qualify(key))
+}
+
+func (s *scopedLoader) GetStringOr(key string, def string) string {
+	return s.parent.GetStringOr(s.qualify(key), def)
+}
+
+func (s *scopedLoader) GetBoolOr(key string, def bool) bool {
+	return s.parent.GetBoolOr(s.qualify(key), def)
+}
+
+func (s *scopedLoader) GetIntOr(key string, def int) int {
+	return s.parent.GetIntOr(s.qualify(key), def)
+}
+
+func (s *scopedLoader) GetDurationOr(key string, def time.Duration) time.Duration {
+	return s.parent.GetDurationOr(s.qualify(key), def)
+}
+
+func (s *scopedLoader) MustGetString(key string) string {
+	return s.parent.MustGetString(s.qualify(key))
+}
+
+func (s *scopedLoader) MustGetBool(key string) bool {
+	return s.parent.MustGetBool(s.qualify(key))
+}
+
+func (s *scopedLoader) MustGetInt(key string) int {
+	return s.parent.MustGetInt(s.qualify(key))
+}
+
+func (s *scopedLoader) MustGetDuration(key string) time.Duration {
+	return s.parent.MustGetDuration(s.qualify(key))
+}
+
+func (s *scopedLoader) MustGetStringSlice(key string) []string {
+	return s.parent.MustGetStringSlice(s.qualify(key))
+}
+
+func (s *scopedLoader) MustGetIntSlice(key string) []int {
+	return s.parent.MustGetIntSlice(s.qualify(key))
+}
+
+func (s *scopedLoader) MustGetStringMap(key string) map[string]string {
+	return s.parent.MustGetStringMap(s.qualify(key))
+}
@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// Status describes how fresh a loader's data is
+type Status struct {
+	// LoadedAt is when the data was last loaded from the backing store
+	LoadedAt time.Time
+	// Stale is true when the data came from a snapshot because the backing store could not be reached
+	Stale bool
+}
+
+// Age is how long ago the data was loaded
+func (s Status) Age() time.Duration {
+	if s.LoadedAt.IsZero() {
+		return 0
+	}
+	return time.Since(s.LoadedAt)
+}
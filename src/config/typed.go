@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is wrapped by the errors loaders return for keys that don't exist, as opposed to keys that exist
+// but can't be read, so callers can tell the two apart with errors.Is
+var ErrNotFound = errors.New("Could not find value for key")
+
+// getFunc fetches the raw json for a key, it lets loaders share the typed parsing below
+type getFunc func(key string) ([]byte, error)
+
+func getJSON(get getFunc, key string, v interface{}) error {
+	b, err := get(key)
+	if err != nil {
+		return fmt.Errorf("Could not fetch config (%s) %w", key, err)
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal config (%s) %v", key, err)
+	}
+	return nil
+}
+
+func getString(get getFunc, key string) (string, error) {
+	var s string
+	err := getJSON(get, key, &s)
+	return s, err
+}
+
+func getBool(get getFunc, key string) (bool, error) {
+	var ret bool
+	err := getJSON(get, key, &ret)
+	return ret, err
+}
+
+func getInt(get getFunc, key string) (int, error) {
+	var ret int
+	err := getJSON(get, key, &ret)
+	return ret, err
+}
+
+func getDuration(get getFunc, key string) (time.Duration, error) {
+	s, err := getString(get, key)
+	if err != nil {
+		return 0, err
+	}
+
+	ret, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("Could not parse config (%s) into a duration: %v", key, err)
+	}
+	return ret, nil
+}
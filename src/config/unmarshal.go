@@ -0,0 +1,403 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Expand rebuilds a nested document from flattened keys such as a/b/c, decoding each stored JSON value.
+// It is the inverse of the flattening done on Import.  Values that are not valid JSON are kept as strings.
+func Expand(kvs map[string][]byte) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(kvs))
+	for k := range kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make(map[string]interface{})
+	for _, k := range keys {
+		//consul folders are stored as empty keys ending with the divider
+		if strings.HasSuffix(k, "/") && len(kvs[k]) == 0 {
+			continue
+		}
+
+		v, err := decodeValue(kvs[k])
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode config (%s) %v", k, err)
+		}
+		err = insert(result, strings.Split(strings.Trim(k, "/"), "/"), v)
+		if err != nil {
+			return nil, fmt.Errorf("Could not expand config (%s) %v", k, err)
+		}
+	}
+	return result, nil
+}
+
+// ExportKeys expands the keys and encodes them as an indented json document
+func ExportKeys(kvs map[string][]byte) ([]byte, error) {
+	tree, err := Expand(kvs)
+	if err != nil {
+		return nil, err
+	}
+	return encodeDocument(tree)
+}
+
+func encodeDocument(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "\t")
+	if err := e.Encode(doc); err != nil {
+		return nil, fmt.Errorf("Could not encode json: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalKeys expands the keys under prefix and decodes them into v.
+// Struct fields are matched against keys using their `consul:"name"` tag or, without one, their name ignoring case.
+// A tag of `consul:"name,required"` makes a missing key an error, `consul:"-"` skips the field and a
+// `default:"value"` tag is used when the key is missing.  Nested structs are decoded from the nested keys and
+// durations may be given as strings such as "5s".
+func UnmarshalKeys(kvs map[string][]byte, prefix string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Unmarshal requires a non-nil pointer, got %T", v)
+	}
+
+	prefix = strings.Trim(prefix, "/")
+	node, found, err := Subtree(kvs, prefix)
+	if err != nil {
+		return err
+	}
+	if !found {
+		//nothing is stored under the prefix, defaults and required fields still apply
+		node = map[string]interface{}{}
+	}
+	return decodeInto(prefix, node, rv.Elem())
+}
+
+// DecodeKey decodes the value stored at key into v.  Like UnmarshalKeys the value may be a single key holding
+// json or a parent of flattened keys, but unlike UnmarshalKeys it is an error for nothing to be stored at key.
+func DecodeKey(kvs map[string][]byte, key string, v interface{}) error {
+	key = strings.Trim(key, "/")
+	node, found, err := Subtree(kvs, key)
+	if err != nil {
+		return fmt.Errorf("Could not fetch config (%s) %v", key, err)
+	}
+	if !found {
+		return fmt.Errorf("Could not fetch config (%s) %w: %s", key, ErrNotFound, key)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("DecodeKey requires a non-nil pointer, got %T", v)
+	}
+	return decodeInto(key, node, rv.Elem())
+}
+
+// Subtree expands the keys at and beneath key and returns the value found at key
+func Subtree(kvs map[string][]byte, key string) (interface{}, bool, error) {
+	key = strings.Trim(key, "/")
+	scoped := make(map[string][]byte)
+	for k, b := range kvs {
+		//ancestors are kept as they may hold the key inside a json object
+		if matchesPrefix(k, key) || matchesPrefix(key, k) {
+			scoped[k] = b
+		}
+	}
+	if len(scoped) == 0 {
+		return nil, false, nil
+	}
+
+	tree, err := Expand(scoped)
+	if err != nil {
+		return nil, false, err
+	}
+	if key == "" {
+		return tree, true, nil
+	}
+	node, ok := lookupPath(tree, strings.Split(key, "/"))
+	return node, ok, nil
+}
+
+func decodeValue(b []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return "", nil
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		//not json, so it must have been written by something other than the importer
+		return string(b), nil
+	}
+	return v, nil
+}
+
+// insert places value at path in tree merging objects that are stored at more than one level
+func insert(tree map[string]interface{}, path []string, value interface{}) error {
+	key := path[0]
+	existing, exists := tree[key]
+
+	if len(path) > 1 {
+		if !exists {
+			existing = make(map[string]interface{})
+			tree[key] = existing
+		}
+		sub, ok := existing.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s holds a value and cannot also hold nested keys", key)
+		}
+		return insert(sub, path[1:], value)
+	}
+
+	if !exists {
+		tree[key] = value
+		return nil
+	}
+	existingMap, ok := existing.(map[string]interface{})
+	valueMap, ok2 := value.(map[string]interface{})
+	if !ok || !ok2 {
+		return fmt.Errorf("%s is defined more than once", key)
+	}
+	for k, v := range valueMap {
+		if err := insert(existingMap, []string{k}, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lookupPath(tree map[string]interface{}, path []string) (interface{}, bool) {
+	var node interface{} = tree
+	for _, p := range path {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		node, ok = lookupKey(m, p)
+		if !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// lookupKey finds name in m preferring an exact match over a case insensitive one
+func lookupKey(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func decodeInto(path string, node interface{}, rv reflect.Value) error {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeInto(path, node, rv.Elem())
+	}
+
+	if rv.Type() == durationType {
+		if s, ok := node.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("Could not parse config (%s) into a duration: %v", path, err)
+			}
+			rv.SetInt(int64(d))
+			return nil
+		}
+	}
+
+	if sub, ok := node.(map[string]interface{}); ok && rv.Kind() == reflect.Struct && !isJSONUnmarshaler(rv) {
+		return decodeStruct(path, sub, rv)
+	}
+
+	//generic targets take the expanded values as they are so numbers keep their precision
+	if node != nil && (rv.Kind() == reflect.Interface || rv.Type() == reflect.TypeOf(map[string]interface{}{})) {
+		if nv := reflect.ValueOf(node); nv.Type().AssignableTo(rv.Type()) {
+			rv.Set(nv)
+			return nil
+		}
+	}
+
+	//everything else is decoded by encoding/json
+	b, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("Could not marshal config (%s) %v", path, err)
+	}
+	err = json.Unmarshal(b, rv.Addr().Interface())
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal config (%s) %v", path, err)
+	}
+	return nil
+}
+
+// flattenInto turns a nested document into a/b/c keys beneath prefix holding json values, the layout Import stores
+func flattenInto(result map[string][]byte, prefix string, data map[string]interface{}) error {
+	for k, v := range data {
+		key := qualifyPath(prefix, k)
+		if sub, ok := v.(map[string]interface{}); ok {
+			if err := flattenInto(result, key, sub); err != nil {
+				return err
+			}
+			continue
+		}
+
+		j, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("Could not marshal config (%s) %v", key, err)
+		}
+		result[key] = j
+	}
+	return nil
+}
+
+// structKeys lists the keys beneath prefix that decoding into a value of type t would read
+func structKeys(t reflect.Type, prefix string) []string {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		if prefix == "" {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, hasTag := f.Tag.Lookup("consul")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			keys = append(keys, structKeys(f.Type, prefix)...)
+			continue
+		}
+		name, _ := parseTag(f.Name, tag)
+		keys = append(keys, structKeys(f.Type, qualifyPath(prefix, name))...)
+	}
+	return keys
+}
+
+func decodeStruct(path string, tree map[string]interface{}, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			//unexported
+			continue
+		}
+		tag, hasTag := f.Tag.Lookup("consul")
+		if tag == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+
+		//untagged embedded structs are decoded from the same level
+		if f.Anonymous && !hasTag && fv.Kind() == reflect.Struct {
+			if err := decodeStruct(path, tree, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, required := parseTag(f.Name, tag)
+		fieldPath := qualifyPath(path, name)
+
+		node, ok := lookupKey(tree, name)
+		if ok {
+			if err := decodeInto(fieldPath, node, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if def, ok := f.Tag.Lookup("default"); ok {
+			if err := decodeDefault(fv, def); err != nil {
+				return fmt.Errorf("Invalid default for config (%s) %v", fieldPath, err)
+			}
+			continue
+		}
+		if required {
+			return fmt.Errorf("Missing required config: %s", fieldPath)
+		}
+		//nested structs may have defaults or required fields of their own
+		if fv.Kind() == reflect.Struct && !isJSONUnmarshaler(fv) && fv.Type() != reflect.TypeOf(time.Time{}) {
+			if err := decodeStruct(fieldPath, map[string]interface{}{}, fv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodeDefault(rv reflect.Value, def string) error {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeDefault(rv.Elem(), def)
+	}
+
+	switch {
+	case rv.Type() == durationType:
+		d, err := time.ParseDuration(def)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	case rv.Kind() == reflect.String:
+		rv.SetString(def)
+		return nil
+	}
+	return json.Unmarshal([]byte(def), rv.Addr().Interface())
+}
+
+func parseTag(fieldName, tag string) (name string, required bool) {
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = fieldName
+	}
+	for _, opt := range parts[1:] {
+		if opt == "required" {
+			required = true
+		}
+	}
+	return name, required
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func isJSONUnmarshaler(rv reflect.Value) bool {
+	_, ok := rv.Addr().Interface().(json.Unmarshaler)
+	return ok
+}
+
+func qualifyPath(prefix, key string) string {
+	if len(prefix) > 0 {
+		return prefix + "/" + key
+	}
+	return key
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		kvs     map[string][]byte
+		want    string
+		wantErr string
+	}{
+		{name: "empty", kvs: map[string][]byte{}, want: `{}`},
+		{name: "nested keys", kvs: map[string][]byte{"a/b": []byte(`1`), "a/c": []byte(`"x"`), "d": []byte(`true`)}, want: `{"a":{"b":1,"c":"x"},"d":true}`},
+		{name: "slashes are trimmed", kvs: map[string][]byte{"/a/b/": []byte(`1`)}, want: `{"a":{"b":1}}`},
+		{name: "folders are skipped", kvs: map[string][]byte{"a/": nil, "a/b": []byte(`1`)}, want: `{"a":{"b":1}}`},
+		{name: "objects at more than one level are merged", kvs: map[string][]byte{"a": []byte(`{"b":1}`), "a/c": []byte(`2`)}, want: `{"a":{"b":1,"c":2}}`},
+		{name: "non json is kept as a string", kvs: map[string][]byte{"a": []byte(`not json`)}, want: `{"a":"not json"}`},
+		{name: "empty value is an empty string", kvs: map[string][]byte{"a": []byte(``)}, want: `{"a":""}`},
+		{name: "large numbers keep their precision", kvs: map[string][]byte{"a": []byte(`12345678901234567890`)}, want: `{"a":12345678901234567890}`},
+		{name: "value and nested keys", kvs: map[string][]byte{"a": []byte(`1`), "a/b": []byte(`2`)}, wantErr: "a holds a value and cannot also hold nested keys"},
+		{name: "defined twice", kvs: map[string][]byte{"a": []byte(`{"b":1}`), "a/b": []byte(`2`)}, wantErr: "b is defined more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.kvs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expand error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand: %v", err)
+			}
+			b, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Fatalf("Expand = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+type testTLS struct {
+	Enabled bool   `consul:"enabled" default:"true"`
+	CA      string `consul:"ca"`
+}
+
+type testDatabase struct {
+	Host     string        `consul:"host,required"`
+	Port     int           `consul:"port" default:"5432"`
+	Timeout  time.Duration `consul:"timeout" default:"5s"`
+	Tags     []string      `consul:"tags"`
+	Password string        `consul:"-"`
+	Name     string
+	TLS      testTLS `consul:"tls"`
+}
+
+func TestUnmarshalKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		kvs     map[string][]byte
+		prefix  string
+		want    testDatabase
+		wantErr string
+	}{
+		{
+			name:   "flattened keys",
+			kvs:    map[string][]byte{"database/host": []byte(`"db"`), "database/port": []byte(`6543`), "database/timeout": []byte(`"1m"`), "database/tags": []byte(`["a","b"]`), "database/tls/ca": []byte(`"ca.pem"`)},
+			prefix: "database",
+			want:   testDatabase{Host: "db", Port: 6543, Timeout: time.Minute, Tags: []string{"a", "b"}, TLS: testTLS{Enabled: true, CA: "ca.pem"}},
+		},
+		{
+			name:   "json object at the prefix",
+			kvs:    map[string][]byte{"database": []byte(`{"host":"db","tls":{"enabled":false}}`)},
+			prefix: "/database/",
+			want:   testDatabase{Host: "db", Port: 5432, Timeout: 5 * time.Second, TLS: testTLS{Enabled: false}},
+		},
+		{
+			name:   "untagged fields match ignoring case and skipped fields are ignored",
+			kvs:    map[string][]byte{"database/host": []byte(`"db"`), "database/NAME": []byte(`"app"`), "database/Password": []byte(`"hunter2"`)},
+			prefix: "database",
+			want:   testDatabase{Host: "db", Name: "app", Port: 5432, Timeout: 5 * time.Second, TLS: testTLS{Enabled: true}},
+		},
+		{
+			name:    "missing required",
+			kvs:     map[string][]byte{"database/port": []byte(`1`)},
+			prefix:  "database",
+			wantErr: "Missing required config: database/host",
+		},
+		{
+			name:    "nothing under the prefix still applies required",
+			kvs:     map[string][]byte{"other": []byte(`1`)},
+			prefix:  "database",
+			wantErr: "Missing required config: database/host",
+		},
+		{
+			name:    "bad duration",
+			kvs:     map[string][]byte{"database/host": []byte(`"db"`), "database/timeout": []byte(`"soon"`)},
+			prefix:  "database",
+			wantErr: "Could not parse config (database/timeout) into a duration",
+		},
+		{
+			name:    "wrong type",
+			kvs:     map[string][]byte{"database/host": []byte(`"db"`), "database/port": []byte(`"x"`)},
+			prefix:  "database",
+			wantErr: "Could not unmarshal config (database/port)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testDatabase
+			err := UnmarshalKeys(tt.kvs, tt.prefix, &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("UnmarshalKeys error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnmarshalKeys: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("UnmarshalKeys = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalKeysRequiresPointer(t *testing.T) {
+	var db testDatabase
+	if err := UnmarshalKeys(nil, "", db); err == nil {
+		t.Fatal("UnmarshalKeys accepted a non pointer")
+	}
+	var nilPtr *testDatabase
+	if err := UnmarshalKeys(nil, "", nilPtr); err == nil {
+		t.Fatal("UnmarshalKeys accepted a nil pointer")
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	kvs := map[string][]byte{"a/b": []byte(`1`), "c": []byte(`{"d":[1,2]}`)}
+	tests := []struct {
+		name     string
+		key      string
+		want     interface{}
+		notFound bool
+	}{
+		{name: "leaf", key: "a/b", want: json.Number("1")},
+		{name: "flattened parent", key: "a", want: map[string]interface{}{"b": json.Number("1")}},
+		{name: "inside a json object", key: "c/d", want: []interface{}{json.Number("1"), json.Number("2")}},
+		{name: "missing", key: "nope", notFound: true},
+		{name: "missing inside a json object", key: "c/e", notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got interface{}
+			err := DecodeKey(kvs, tt.key, &got)
+			if tt.notFound {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("DecodeKey error = %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeKey: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DecodeKey = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Package consulconfig holds the consul connection options shared by the config loader and the balancer
+package consulconfig
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Option changes the config a consul client is built from
+type Option func(*api.Config)
+
+// Replace replaces the config with a copy of conf, keeping the current address when conf has none
+func Replace(conf *api.Config) Option {
+	return func(c *api.Config) {
+		addr := c.Address
+		*c = *conf
+		if c.Address == "" {
+			c.Address = addr
+		}
+	}
+}
+
+// Token sets the ACL token sent with every request
+func Token(token string) Option {
+	return func(c *api.Config) {
+		c.Token = token
+	}
+}
+
+// Datacenter talks to the given datacenter instead of the agent's
+func Datacenter(dc string) Option {
+	return func(c *api.Config) {
+		c.Datacenter = dc
+	}
+}
+
+// HTTPAuth sets the http basic auth credentials sent with every request
+func HTTPAuth(username, password string) Option {
+	return func(c *api.Config) {
+		c.HttpAuth = &api.HttpBasicAuth{Username: username, Password: password}
+	}
+}
+
+// HTTPClient sends requests with client
+func HTTPClient(client *http.Client) Option {
+	return func(c *api.Config) {
+		c.HttpClient = client
+	}
+}
+
+// TLSConfig talks to consul over https using conf for the client certificates and trusted CAs.
+// The http client is copied so a client shared with HTTPClient is left alone.
+func TLSConfig(conf *tls.Config) Option {
+	return func(c *api.Config) {
+		c.Scheme = "https"
+		c.HttpClient = tlsClient(c.HttpClient, conf)
+	}
+}
+
+// tlsClient copies client using conf for its tls connections
+func tlsClient(client *http.Client, conf *tls.Config) *http.Client {
+	var transport *http.Transport
+	if client != nil {
+		if t, ok := client.Transport.(*http.Transport); ok {
+			transport = t.Clone()
+		}
+	}
+	if transport == nil {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.TLSClientConfig = conf
+
+	copied := &http.Client{}
+	if client != nil {
+		*copied = *client
+	}
+	copied.Transport = transport
+	return copied
+}
@@ -0,0 +1,31 @@
+// Package debugview renders the debug pages of the config loader and the balancer
+package debugview
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Render writes view as indented json, or with tmpl when the request asked for html with ?format=html or an
+// Accept header
+func Render(w http.ResponseWriter, r *http.Request, tmpl *template.Template, view interface{}) {
+	if WantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		tmpl.Execute(w, view)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	e.Encode(view)
+}
+
+// WantsHTML reports whether the request asked for html rather than json
+func WantsHTML(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "html"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
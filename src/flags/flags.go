@@ -0,0 +1,199 @@
+package flags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/divideandconquer/go-consul-client/src/config"
+)
+
+// Flag is the definition of a feature flag as stored beneath the flags prefix, e.g.
+// {"new-checkout": {"enabled": true, "rollout": 25, "deny": ["user-1"], "rules": [{"attribute": "country", "op": "in", "values": ["US", "CA"]}]}}
+type Flag struct {
+	// Enabled turns the flag off for everyone when false
+	Enabled bool `json:"enabled"`
+	// Rollout is the percentage (0-100) of keys the flag is on for, it defaults to 100
+	Rollout *float64 `json:"rollout,omitempty"`
+	// Allow lists keys the flag is always on for while it is enabled
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists keys the flag is always off for
+	Deny []string `json:"deny,omitempty"`
+	// Rules must all match the context's attributes for the flag to be on, allowed keys skip them
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// Rule matches an attribute of the context against values
+type Rule struct {
+	Attribute string `json:"attribute"`
+	// Op is one of eq, neq, in, not_in, prefix, suffix or contains.  eq and neq compare against the first value.
+	Op     string   `json:"op"`
+	Values []string `json:"values"`
+}
+
+// Context is who a flag is evaluated for
+type Context struct {
+	// Key identifies the user, account or request, it is matched against allow and deny and decides the rollout bucket
+	Key        string
+	Attributes map[string]string
+}
+
+// Flags evaluates the flags defined beneath a prefix of a loader and reloads them whenever the loader changes
+type Flags struct {
+	loader      config.Loader
+	prefix      string
+	flags       atomic.Value // map[string]Flag
+	reloadLock  sync.Mutex
+	unsubscribe func()
+
+	errLock sync.Mutex
+	lastErr error
+}
+
+// New loads the flag definitions beneath prefix and keeps them up to date as the loader reloads.
+// Call Close to stop following the loader.
+func New(loader config.Loader, prefix string) (*Flags, error) {
+	f := &Flags{loader: loader, prefix: strings.Trim(prefix, "/")}
+	err := f.reload()
+	if err != nil {
+		return nil, err
+	}
+	//reloaded once per reload of the loader however many flags it changed
+	f.unsubscribe = loader.OnChanges(f.prefix, func(events []config.ChangeEvent) {
+		f.setErr(f.reload())
+	})
+	return f, nil
+}
+
+// reload replaces the definitions, a broken definition keeps the last good ones in place
+func (f *Flags) reload() error {
+	//serialized so an older read can't replace a newer one
+	f.reloadLock.Lock()
+	defer f.reloadLock.Unlock()
+
+	defs := make(map[string]Flag)
+	err := f.loader.Unmarshal(f.prefix, &defs)
+	if err != nil {
+		return fmt.Errorf("Could not load flags (%s) %v", f.prefix, err)
+	}
+	for name, def := range defs {
+		for _, r := range def.Rules {
+			if !validOp(r.Op) {
+				return fmt.Errorf("Could not load flags (%s) flag %s has a rule with unknown op: %s", f.prefix, name, r.Op)
+			}
+		}
+	}
+	f.flags.Store(defs)
+	return nil
+}
+
+func (f *Flags) setErr(err error) {
+	f.errLock.Lock()
+	defer f.errLock.Unlock()
+	f.lastErr = err
+}
+
+// Err returns the error from the most recent reload, nil if the definitions in use are current
+func (f *Flags) Err() error {
+	f.errLock.Lock()
+	defer f.errLock.Unlock()
+	return f.lastErr
+}
+
+// Close stops following changes to the loader, the last definitions are still evaluated
+func (f *Flags) Close() {
+	f.unsubscribe()
+}
+
+// Get returns the definition of the flag
+func (f *Flags) Get(name string) (Flag, bool) {
+	def, ok := f.flags.Load().(map[string]Flag)[name]
+	return def, ok
+}
+
+// IsEnabled reports whether the flag is on for ctx.  Unknown and disabled flags are off, denied keys are off,
+// allowed keys are on, then every rule must match and the key must fall within the rollout percentage.
+// The rollout bucket is a hash of the flag name and key so a key keeps its answer as the percentage grows.
+func (f *Flags) IsEnabled(name string, ctx Context) bool {
+	def, ok := f.Get(name)
+	if !ok || !def.Enabled {
+		return false
+	}
+	if contains(def.Deny, ctx.Key) {
+		return false
+	}
+	if contains(def.Allow, ctx.Key) {
+		return true
+	}
+	for _, r := range def.Rules {
+		if !r.matches(ctx.Attributes) {
+			return false
+		}
+	}
+
+	if def.Rollout == nil {
+		return true
+	}
+	return bucket(name, ctx.Key) < *def.Rollout
+}
+
+// bucket places the key of a flag between 0 and 100 with two decimals of precision
+func bucket(name, key string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + key))
+	return float64(h.Sum32()%10000) / 100
+}
+
+func validOp(op string) bool {
+	switch op {
+	case "eq", "neq", "in", "not_in", "prefix", "suffix", "contains":
+		return true
+	}
+	return false
+}
+
+func (r Rule) matches(attributes map[string]string) bool {
+	v, ok := attributes[r.Attribute]
+	first := ""
+	if len(r.Values) > 0 {
+		first = r.Values[0]
+	}
+
+	switch r.Op {
+	case "eq":
+		return ok && v == first
+	case "neq":
+		return !ok || v != first
+	case "in":
+		return ok && contains(r.Values, v)
+	case "not_in":
+		return !ok || !contains(r.Values, v)
+	case "prefix":
+		return ok && matchesAny(r.Values, func(s string) bool { return strings.HasPrefix(v, s) })
+	case "suffix":
+		return ok && matchesAny(r.Values, func(s string) bool { return strings.HasSuffix(v, s) })
+	case "contains":
+		return ok && matchesAny(r.Values, func(s string) bool { return strings.Contains(v, s) })
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(list []string, match func(string) bool) bool {
+	for _, l := range list {
+		if match(l) {
+			return true
+		}
+	}
+	return false
+}
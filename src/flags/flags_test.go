@@ -0,0 +1,155 @@
+package flags
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/divideandconquer/go-consul-client/src/config"
+)
+
+const testFlags = `{"flags": {
+	"off": {"enabled": false},
+	"on": {"enabled": true},
+	"lists": {"enabled": true, "rollout": 0, "allow": ["friend", "foe"], "deny": ["foe"], "rules": [{"attribute": "country", "op": "eq", "values": ["US"]}]},
+	"us": {"enabled": true, "rules": [{"attribute": "country", "op": "in", "values": ["US", "CA"]}, {"attribute": "plan", "op": "neq", "values": ["free"]}]},
+	"none": {"enabled": true, "rollout": 0},
+	"all": {"enabled": true, "rollout": 100},
+	"quarter": {"enabled": true, "rollout": 25}
+}}`
+
+func newTestFlags(t *testing.T, doc string) *Flags {
+	t.Helper()
+	m, err := config.NewMappedLoader([]byte(doc))
+	if err != nil {
+		t.Fatalf("NewMappedLoader: %v", err)
+	}
+	f, err := New(m, "flags")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestIsEnabled(t *testing.T) {
+	f := newTestFlags(t, testFlags)
+	tests := []struct {
+		name string
+		flag string
+		ctx  Context
+		want bool
+	}{
+		{name: "unknown flag", flag: "nope", ctx: Context{Key: "u"}, want: false},
+		{name: "disabled", flag: "off", ctx: Context{Key: "u"}, want: false},
+		{name: "enabled", flag: "on", ctx: Context{Key: "u"}, want: true},
+		{name: "allowed skips rules and rollout", flag: "lists", ctx: Context{Key: "friend"}, want: true},
+		{name: "deny wins over allow", flag: "lists", ctx: Context{Key: "foe"}, want: false},
+		{name: "not allowed", flag: "lists", ctx: Context{Key: "u", Attributes: map[string]string{"country": "US"}}, want: false},
+		{name: "every rule matches", flag: "us", ctx: Context{Key: "u", Attributes: map[string]string{"country": "CA", "plan": "pro"}}, want: true},
+		{name: "one rule fails", flag: "us", ctx: Context{Key: "u", Attributes: map[string]string{"country": "CA", "plan": "free"}}, want: false},
+		{name: "missing attribute", flag: "us", ctx: Context{Key: "u"}, want: false},
+		{name: "zero rollout", flag: "none", ctx: Context{Key: "u"}, want: false},
+		{name: "full rollout", flag: "all", ctx: Context{Key: "u"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.IsEnabled(tt.flag, tt.ctx); got != tt.want {
+				t.Fatalf("IsEnabled(%s, %+v) = %v, want %v", tt.flag, tt.ctx, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollout(t *testing.T) {
+	f := newTestFlags(t, testFlags)
+	on := 0
+	for i := 0; i < 2000; i++ {
+		ctx := Context{Key: fmt.Sprintf("user-%d", i)}
+		enabled := f.IsEnabled("quarter", ctx)
+		if enabled != f.IsEnabled("quarter", ctx) {
+			t.Fatalf("%s got a different answer on the second call", ctx.Key)
+		}
+		if enabled {
+			on++
+			//the bucket doesn't move so a key that is on stays on as the rollout grows
+			if b := bucket("quarter", ctx.Key); b >= 25 || b < 0 {
+				t.Fatalf("%s is on with bucket %v", ctx.Key, b)
+			}
+		}
+	}
+	//roughly a quarter of the keys
+	if on < 400 || on > 600 {
+		t.Fatalf("%d of 2000 keys are on at a 25%% rollout", on)
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	attrs := map[string]string{"country": "US", "email": "a@example.com"}
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{name: "eq", rule: Rule{Attribute: "country", Op: "eq", Values: []string{"US"}}, want: true},
+		{name: "eq uses the first value", rule: Rule{Attribute: "country", Op: "eq", Values: []string{"CA", "US"}}, want: false},
+		{name: "eq missing attribute", rule: Rule{Attribute: "plan", Op: "eq", Values: []string{""}}, want: false},
+		{name: "neq", rule: Rule{Attribute: "country", Op: "neq", Values: []string{"CA"}}, want: true},
+		{name: "neq missing attribute", rule: Rule{Attribute: "plan", Op: "neq", Values: []string{"free"}}, want: true},
+		{name: "in", rule: Rule{Attribute: "country", Op: "in", Values: []string{"CA", "US"}}, want: true},
+		{name: "in no match", rule: Rule{Attribute: "country", Op: "in", Values: []string{"CA"}}, want: false},
+		{name: "not_in", rule: Rule{Attribute: "country", Op: "not_in", Values: []string{"CA"}}, want: true},
+		{name: "not_in match", rule: Rule{Attribute: "country", Op: "not_in", Values: []string{"US"}}, want: false},
+		{name: "not_in missing attribute", rule: Rule{Attribute: "plan", Op: "not_in", Values: []string{"free"}}, want: true},
+		{name: "prefix", rule: Rule{Attribute: "email", Op: "prefix", Values: []string{"b@", "a@"}}, want: true},
+		{name: "suffix", rule: Rule{Attribute: "email", Op: "suffix", Values: []string{"@example.com"}}, want: true},
+		{name: "suffix no match", rule: Rule{Attribute: "email", Op: "suffix", Values: []string{"@example.org"}}, want: false},
+		{name: "contains", rule: Rule{Attribute: "email", Op: "contains", Values: []string{"example"}}, want: true},
+		{name: "contains missing attribute", rule: Rule{Attribute: "plan", Op: "contains", Values: []string{""}}, want: false},
+		{name: "unknown op", rule: Rule{Attribute: "country", Op: "like", Values: []string{"US"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(attrs); got != tt.want {
+				t.Fatalf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	m, err := config.NewMappedLoader([]byte(`{"flags": {"f": {"enabled": true}}}`))
+	if err != nil {
+		t.Fatalf("NewMappedLoader: %v", err)
+	}
+	f, err := New(m, "/flags/")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer f.Close()
+
+	if err := m.Put("flags/f/enabled", []byte(`false`)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if f.IsEnabled("f", Context{}) || f.Err() != nil {
+		t.Fatalf("flag is still on after it was disabled, err %v", f.Err())
+	}
+
+	//a broken definition keeps the last good ones
+	if err := m.Put("flags/g", []byte(`{"enabled": true, "rules": [{"attribute": "a", "op": "like"}]}`)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := f.Err(); err == nil || !strings.Contains(err.Error(), "unknown op: like") {
+		t.Fatalf("Err = %v, want the unknown op", err)
+	}
+	if _, ok := f.Get("g"); ok {
+		t.Fatal("the broken definition was loaded")
+	}
+	if _, ok := f.Get("f"); !ok {
+		t.Fatal("the last good definitions were dropped")
+	}
+
+	if _, err := New(m, "flags"); err == nil {
+		t.Fatal("New accepted a broken definition")
+	}
+}
@@ -0,0 +1,65 @@
+package instrument
+
+import (
+	"context"
+	"time"
+)
+
+// Labels are the dimensions of a metric or attributes of a span, e.g. namespace or service
+type Labels map[string]string
+
+// Instrumentation receives the metrics and spans recorded by the loaders and balancers.
+// Implementations must be safe for concurrent use.
+type Instrumentation interface {
+	// IncCounter adds one to the counter
+	IncCounter(name string, labels Labels)
+	// Observe records a value, such as a latency in seconds, in the histogram
+	Observe(name string, value float64, labels Labels)
+	// StartSpan starts a span that is ended by calling End on the returned Span.
+	// The returned context carries the span to any spans started beneath it.
+	StartSpan(ctx context.Context, name string, labels Labels) (context.Context, Span)
+}
+
+// CounterFuncs is implemented by instrumentations that can read counters kept by the caller.  It is used for
+// counters on hot paths, such as loader_gets_total, that are too frequent to report one IncCounter at a time.
+type CounterFuncs interface {
+	// CounterFunc reports the value returned by fn as the counter whenever the metrics are read.  Funcs
+	// registered more than once for the same name and labels are summed.
+	CounterFunc(name string, labels Labels, fn func() float64)
+}
+
+// Span is a timed operation started by StartSpan
+type Span interface {
+	// End finishes the span, err is the outcome of the operation and may be nil
+	End(err error)
+}
+
+// Noop discards everything, it is the default for loaders and balancers
+var Noop Instrumentation = noop{}
+
+type noop struct{}
+
+func (noop) IncCounter(name string, labels Labels) {}
+
+func (noop) Observe(name string, value float64, labels Labels) {}
+
+func (noop) StartSpan(ctx context.Context, name string, labels Labels) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+// Result is the value of the result label for the outcome err
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// Since is the time elapsed since start in seconds, the unit histograms of latencies are recorded in
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
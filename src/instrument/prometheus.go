@@ -0,0 +1,253 @@
+package instrument
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the histogram upper bounds in seconds used by NewPrometheus
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Prometheus keeps counters and histograms in memory and serves them in the Prometheus text format.
+// Spans are recorded as a histogram of their duration named after the span with a _seconds suffix and a
+// result label.
+type Prometheus struct {
+	prefix  string
+	buckets []float64
+
+	lock         sync.Mutex
+	counters     map[string]map[string]float64
+	counterFuncs map[string]map[string][]func() float64
+	histograms   map[string]map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewPrometheus creates an in memory Prometheus adapter, prefix is prepended to every metric name
+func NewPrometheus(prefix string) *Prometheus {
+	return &Prometheus{
+		prefix:       prefix,
+		buckets:      DefaultBuckets,
+		counters:     make(map[string]map[string]float64),
+		counterFuncs: make(map[string]map[string][]func() float64),
+		histograms:   make(map[string]map[string]*histogram),
+	}
+}
+
+// IncCounter adds one to the counter
+func (p *Prometheus) IncCounter(name string, labels Labels) {
+	name, key := p.name(name), formatLabels(labels)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	series, ok := p.counters[name]
+	if !ok {
+		series = make(map[string]float64)
+		p.counters[name] = series
+	}
+	series[key]++
+}
+
+// CounterFunc reports the value returned by fn as the counter, it is called every time the metrics are written.
+// Funcs registered for the same name and labels, e.g. by two loaders sharing the adapter, are summed.
+func (p *Prometheus) CounterFunc(name string, labels Labels, fn func() float64) {
+	name, key := p.name(name), formatLabels(labels)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	series, ok := p.counterFuncs[name]
+	if !ok {
+		series = make(map[string][]func() float64)
+		p.counterFuncs[name] = series
+	}
+	series[key] = append(series[key], fn)
+}
+
+// Observe records the value in the histogram
+func (p *Prometheus) Observe(name string, value float64, labels Labels) {
+	name, key := p.name(name), formatLabels(labels)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	series, ok := p.histograms[name]
+	if !ok {
+		series = make(map[string]*histogram)
+		p.histograms[name] = series
+	}
+	h, ok := series[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		series[key] = h
+	}
+	for i, le := range p.buckets {
+		if value <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// StartSpan starts timing a span, its duration is recorded when it ends
+func (p *Prometheus) StartSpan(ctx context.Context, name string, labels Labels) (context.Context, Span) {
+	return ctx, &promSpan{p: p, name: name, labels: labels, start: time.Now()}
+}
+
+type promSpan struct {
+	p      *Prometheus
+	name   string
+	labels Labels
+	start  time.Time
+}
+
+func (s *promSpan) End(err error) {
+	labels := Labels{"result": Result(err)}
+	for k, v := range s.labels {
+		labels[k] = v
+	}
+	s.p.Observe(s.name+"_seconds", Since(s.start), labels)
+}
+
+// ServeHTTP writes every metric in the Prometheus text exposition format
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	p.lock.Lock()
+	counters := make(map[string]map[string]float64, len(p.counters)+len(p.counterFuncs))
+	for name, series := range p.counters {
+		counters[name] = make(map[string]float64, len(series))
+		for key, v := range series {
+			counters[name][key] = v
+		}
+	}
+	for name, series := range p.counterFuncs {
+		if counters[name] == nil {
+			counters[name] = make(map[string]float64, len(series))
+		}
+		for key, fns := range series {
+			for _, fn := range fns {
+				counters[name][key] += fn()
+			}
+		}
+	}
+
+	var names []string
+	for name := range counters {
+		names = append(names, name)
+	}
+	for _, name := range sortedNames(names) {
+		fmt.Fprintf(&b, "# TYPE %s counter\n", name)
+		series := counters[name]
+		var keys []string
+		for key := range series {
+			keys = append(keys, key)
+		}
+		for _, key := range sortedNames(keys) {
+			fmt.Fprintf(&b, "%s%s %s\n", name, braces(key), formatFloat(series[key]))
+		}
+	}
+	names = names[:0]
+	for name := range p.histograms {
+		names = append(names, name)
+	}
+	for _, name := range sortedNames(names) {
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		series := p.histograms[name]
+		var keys []string
+		for key := range series {
+			keys = append(keys, key)
+		}
+		for _, key := range sortedNames(keys) {
+			h := series[key]
+			var cumulative uint64
+			for i, le := range p.buckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braces(joinLabels(key, `le="`+formatFloat(le)+`"`)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braces(joinLabels(key, `le="+Inf"`)), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, braces(key), formatFloat(h.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, braces(key), h.count)
+		}
+	}
+	p.lock.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (p *Prometheus) name(name string) string {
+	if p.prefix == "" {
+		return sanitize(name)
+	}
+	return sanitize(p.prefix + "_" + name)
+}
+
+// formatLabels renders labels sorted by name so the same labels always produce the same series
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		parts = append(parts, sanitize(k)+`="`+escape(labels[k])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// sanitize replaces the characters that aren't allowed in metric and label names
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedNames(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,67 @@
+package instrument
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusCounters(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(p *Prometheus)
+		want     []string
+	}{
+		{
+			name: "inc counter",
+			register: func(p *Prometheus) {
+				p.IncCounter("gets", Labels{"result": "hit"})
+				p.IncCounter("gets", Labels{"result": "hit"})
+			},
+			want: []string{`app_gets{result="hit"} 2`},
+		},
+		{
+			name: "counter funcs are read when written",
+			register: func(p *Prometheus) {
+				p.CounterFunc("gets", Labels{"result": "hit"}, func() float64 { return 5 })
+				p.CounterFunc("gets", Labels{"result": "miss"}, func() float64 { return 1 })
+			},
+			want: []string{`app_gets{result="hit"} 5`, `app_gets{result="miss"} 1`},
+		},
+		{
+			name: "counter funcs for the same series are summed",
+			register: func(p *Prometheus) {
+				p.CounterFunc("gets", Labels{"result": "hit"}, func() float64 { return 5 })
+				p.CounterFunc("gets", Labels{"result": "hit"}, func() float64 { return 1 })
+			},
+			want: []string{`app_gets{result="hit"} 6`},
+		},
+		{
+			name: "counter funcs are added to inc counters",
+			register: func(p *Prometheus) {
+				p.IncCounter("gets", Labels{"result": "hit"})
+				p.CounterFunc("gets", Labels{"result": "hit"}, func() float64 { return 2 })
+			},
+			want: []string{`app_gets{result="hit"} 3`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrometheus("app")
+			tt.register(p)
+
+			var b bytes.Buffer
+			if _, err := p.WriteTo(&b); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if strings.Count(b.String(), "# TYPE app_gets counter") != 1 {
+				t.Fatalf("WriteTo = %s, want a single app_gets counter", b.String())
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(b.String(), line+"\n") {
+					t.Fatalf("WriteTo = %s, want a line %s", b.String(), line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields are the structured attributes of a log event, e.g. namespace, keys or error
+type Fields map[string]interface{}
+
+// Logger receives the events logged by the loaders, balancers and the importer.
+// Implementations must be safe for concurrent use, adapters for other logging libraries only need these four methods.
+type Logger interface {
+	Debug(msg string, fields Fields)
+	Info(msg string, fields Fields)
+	Warn(msg string, fields Fields)
+	Error(msg string, fields Fields)
+}
+
+// Nop discards every event, it is the default for loaders and balancers
+var Nop Logger = nop{}
+
+type nop struct{}
+
+func (nop) Debug(msg string, fields Fields) {}
+func (nop) Info(msg string, fields Fields)  {}
+func (nop) Warn(msg string, fields Fields)  {}
+func (nop) Error(msg string, fields Fields) {}
+
+// Level orders events by severity
+type Level int
+
+const (
+	// LevelDebug is for detail only needed while debugging
+	LevelDebug Level = iota
+	// LevelInfo is for routine events such as a namespace being loaded
+	LevelInfo
+	// LevelWarn is for failures that are recovered from, such as falling back to a snapshot
+	LevelWarn
+	// LevelError is for failures that aren't recovered from
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// writer is the shared implementation of the text and json loggers
+type writer struct {
+	lock   sync.Mutex
+	out    io.Writer
+	min    Level
+	format func(t time.Time, level Level, msg string, fields Fields) []byte
+}
+
+func (w *writer) log(level Level, msg string, fields Fields) {
+	if level < w.min {
+		return
+	}
+	line := w.format(time.Now(), level, msg, fields)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.out.Write(line)
+}
+
+func (w *writer) Debug(msg string, fields Fields) { w.log(LevelDebug, msg, fields) }
+func (w *writer) Info(msg string, fields Fields)  { w.log(LevelInfo, msg, fields) }
+func (w *writer) Warn(msg string, fields Fields)  { w.log(LevelWarn, msg, fields) }
+func (w *writer) Error(msg string, fields Fields) { w.log(LevelError, msg, fields) }
+
+// NewText creates a Logger that writes events of at least min level to out as lines of
+// time level msg key=value pairs with the keys sorted
+func NewText(out io.Writer, min Level) Logger {
+	return &writer{out: out, min: min, format: formatText}
+}
+
+// NewJSON creates a Logger that writes events of at least min level to out as one json object per line
+// with time, level and msg alongside the fields
+func NewJSON(out io.Writer, min Level) Logger {
+	return &writer{out: out, min: min, format: formatJSON}
+}
+
+func formatText(t time.Time, level Level, msg string, fields Fields) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", t.Format(time.RFC3339), strings.ToUpper(level.String()), msg)
+	for _, k := range sortedKeys(fields) {
+		v := fmt.Sprint(value(fields[k]))
+		if strings.ContainsAny(v, " \"=\n") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", k, v)
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func formatJSON(t time.Time, level Level, msg string, fields Fields) []byte {
+	event := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		event[k] = value(v)
+	}
+	event["time"] = t.Format(time.RFC3339Nano)
+	event["level"] = level.String()
+	event["msg"] = msg
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{"time": event["time"], "level": event["level"], "msg": msg, "error": err.Error()})
+	}
+	return append(line, '\n')
+}
+
+// value converts errors to their message as they would otherwise encode as empty json objects
+func value(v interface{}) interface{} {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return v
+}
+
+func sortedKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
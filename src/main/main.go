@@ -2,55 +2,210 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"strings"
 
 	"github.com/divideandconquer/go-consul-client/src/client"
+	"github.com/divideandconquer/go-consul-client/src/config"
+	"github.com/divideandconquer/go-consul-client/src/logging"
+	"github.com/divideandconquer/go-consul-client/src/secret"
 )
 
-var filepath = flag.String("file", "", "the path to the json file")
+var filepath = flag.String("file", "", "the path to the config file")
 var namespace = flag.String("namespace", "", "the consul namespace to use as a prefix")
 var consulAddr = flag.String("consul", "", "the consul address to use as a prefix")
+var format = flag.String("format", "", "the format of the file: json, yaml, toml or hcl (defaults to the file extension)")
+var mode = flag.String("mode", "import", "import the file into consul or export the namespace from consul")
+var prune = flag.Bool("prune", false, "delete keys in the namespace that are not in the json file, requires -namespace")
+var dryRun = flag.Bool("dry-run", false, "print the changes the import would make without making them")
+var encrypt = flag.String("encrypt", "", "comma separated keys to encrypt before they are written, e.g. database/password")
+var keyringPath = flag.String("keyring", "", "the path to the keyring used by -encrypt")
+var schemaPath = flag.String("schema", "", "the path to a json schema the file must match before it is imported")
+var logFormat = flag.String("log-format", "text", "the format of log lines: text or json")
+
+var logger = logging.NewText(os.Stderr, logging.LevelInfo)
+
+// fatal logs the error and exits
+func fatal(msg string, fields logging.Fields) {
+	logger.Error(msg, fields)
+	os.Exit(1)
+}
 
 func main() {
 	flag.Parse()
-	if filepath == nil || *filepath == "" {
-		log.Printf("Missing parameter -file")
+	switch *logFormat {
+	case "text":
+	case "json":
+		logger = logging.NewJSON(os.Stderr, logging.LevelInfo)
+	default:
+		logger.Error("Unknown log format", logging.Fields{"format": *logFormat})
 		printHelp()
 	}
-
 	if consulAddr == nil || *consulAddr == "" {
-		log.Printf("Missing parameter -consul")
+		logger.Error("Missing parameter -consul", nil)
+		printHelp()
+	}
+
+	opts := []client.Option{client.WithLogger(logger)}
+	if schemaPath != nil && *schemaPath != "" {
+		schema, err := config.LoadSchema(*schemaPath)
+		if err != nil {
+			fatal("Error loading schema", logging.Fields{"schema": *schemaPath, "error": err})
+		}
+		opts = append(opts, client.WithSchema(schema))
+	}
+
+	loader, err := client.NewCachedLoader(*namespace, *consulAddr, opts...)
+	if err != nil {
+		fatal("Error creating loader", logging.Fields{"error": err})
+	}
+
+	switch *mode {
+	case "import":
+		runImport(loader)
+	case "export":
+		runExport(loader)
+	default:
+		logger.Error("Unknown mode", logging.Fields{"mode": *mode})
+		printHelp()
+	}
+}
+
+func runImport(loader config.Loader) {
+	if filepath == nil || *filepath == "" {
+		logger.Error("Missing parameter -file", nil)
+		printHelp()
+	}
+	if *prune && strings.Trim(*namespace, "/") == "" {
+		logger.Error("Missing parameter -namespace, -prune would delete every key in consul that isn't in the file", nil)
 		printHelp()
 	}
 
 	if _, err := os.Stat(*filepath); os.IsNotExist(err) {
-		log.Fatalf("Given file does not exist: %s", *filepath)
+		fatal("Given file does not exist", logging.Fields{"file": *filepath})
 	}
 	data, err := ioutil.ReadFile(*filepath)
 	if err != nil {
-		log.Fatalf("Error reading file %s : %v", *filepath, err)
+		fatal("Error reading file", logging.Fields{"file": *filepath, "error": err})
 	}
 
-	loader, err := client.NewCachedLoader(*namespace, *consulAddr)
+	fileFormat := *format
+	if fileFormat == "" {
+		fileFormat = config.FormatForFile(*filepath)
+	}
+	data, err = config.ToJSON(fileFormat, data)
 	if err != nil {
-		log.Fatalf("Error creating loader: %v", err)
+		fatal("Error reading file", logging.Fields{"file": *filepath, "error": err})
+	}
+
+	if *encrypt != "" {
+		data = encryptKeys(loader, data)
+	}
+
+	if *dryRun {
+		plan, err := loader.ImportPlan(data)
+		if err != nil {
+			fatal("Error planning import", logging.Fields{"namespace": *namespace, "error": err})
+		}
+		printPlan(plan, *prune)
+		return
 	}
 
-	err = loader.Import(data)
+	if *prune {
+		err = loader.Sync(data)
+	} else {
+		err = loader.Import(data)
+	}
+	if err != nil {
+		fatal("Error importing data", logging.Fields{"namespace": *namespace, "error": err})
+	}
+	logger.Info("Config successfully loaded", logging.Fields{"file": *filepath, "namespace": *namespace, "prune": *prune})
+}
+
+// encryptKeys encrypts the keys listed by -encrypt with the primary key of the -keyring.  Values that are
+// unchanged keep the ciphertext already in consul so they aren't rewritten or reported as changed.
+func encryptKeys(loader config.Loader, data []byte) []byte {
+	if keyringPath == nil || *keyringPath == "" {
+		logger.Error("Missing parameter -keyring", nil)
+		printHelp()
+	}
+	keyring, err := secret.LoadKeyring(*keyringPath)
+	if err != nil {
+		fatal("Error loading keyring", logging.Fields{"keyring": *keyringPath, "error": err})
+	}
+
+	var keys []string
+	for _, k := range strings.Split(*encrypt, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	current, err := loader.Export()
+	if err != nil {
+		fatal("Error reading the namespace", logging.Fields{"namespace": *namespace, "error": err})
+	}
+	data, err = keyring.EncryptKeysReusing(data, current, keys)
 	if err != nil {
-		log.Fatalf("Error importing data: %v", err)
+		fatal("Error encrypting data", logging.Fields{"keys": *encrypt, "error": err})
+	}
+	return data
+}
+
+// runExport writes the namespace as json to the file, or to stdout if no file was given
+func runExport(loader config.Loader) {
+	data, err := loader.Export()
+	if err != nil {
+		fatal("Error exporting data", logging.Fields{"namespace": *namespace, "error": err})
+	}
+
+	if filepath == nil || *filepath == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	err = ioutil.WriteFile(*filepath, data, 0644)
+	if err != nil {
+		fatal("Error writing file", logging.Fields{"file": *filepath, "error": err})
+	}
+	logger.Info("Json successfully exported", logging.Fields{"file": *filepath, "namespace": *namespace})
+}
+
+// printPlan writes the planned changes to stdout, deletions are only shown if they would be made
+func printPlan(plan []config.ChangeEvent, prune bool) {
+	changes := 0
+	for _, e := range plan {
+		switch e.Type {
+		case config.KeyAdded:
+			fmt.Printf("+ %s = %s\n", e.Key, e.New)
+		case config.KeyModified:
+			fmt.Printf("~ %s = %s -> %s\n", e.Key, e.Old, e.New)
+		case config.KeyDeleted:
+			if !prune {
+				continue
+			}
+			fmt.Printf("- %s = %s\n", e.Key, e.Old)
+		}
+		changes++
 	}
-	log.Printf("Json from %s successfully loaded", *filepath)
+	fmt.Printf("%d change(s) planned, nothing was written\n", changes)
 }
 
 func printHelp() {
-	log.Println("Consul Client importer will import a json file into a consul KV store.")
-	log.Println("Usage: ")
-	log.Println("bin/importer -file /path/to/json/file -namespace dev/config")
-	log.Println(" -file is the path to a json file to import")
-	log.Println(" -namespace is a prefix to use in consul")
-	log.Println(" -consul is the address for consul. e.g. 172.17.8.101:8500")
+	fmt.Fprintln(os.Stderr, "Consul Client importer will import a json file into a consul KV store or export a namespace back to json.")
+	fmt.Fprintln(os.Stderr, "Usage: ")
+	fmt.Fprintln(os.Stderr, "bin/importer -file /path/to/json/file -namespace dev/config")
+	fmt.Fprintln(os.Stderr, "bin/importer -mode export -file /path/to/json/file -namespace dev/config")
+	fmt.Fprintln(os.Stderr, " -file is the path to a json file to import, or to export to (stdout if omitted)")
+	fmt.Fprintln(os.Stderr, " -mode is import (the default) or export")
+	fmt.Fprintln(os.Stderr, " -format is json, yaml, toml or hcl and defaults to the file extension")
+	fmt.Fprintln(os.Stderr, " -namespace is a prefix to use in consul")
+	fmt.Fprintln(os.Stderr, " -consul is the address for consul. e.g. 172.17.8.101:8500")
+	fmt.Fprintln(os.Stderr, " -dry-run prints the keys that would be added, changed or removed without writing them")
+	fmt.Fprintln(os.Stderr, " -prune deletes keys in the namespace that are not in the json file")
+	fmt.Fprintln(os.Stderr, " -log-format is text (the default) or json")
+	fmt.Fprintln(os.Stderr, " -schema is the path to a json schema, the import is refused if the file doesn't match it")
+	fmt.Fprintln(os.Stderr, " -encrypt is a comma separated list of keys to encrypt before they are written, e.g. database/password")
+	fmt.Fprintln(os.Stderr, " -keyring is the path to the keyring for -encrypt, one id=base64key per line with the primary key first")
 	os.Exit(1)
 }
@@ -0,0 +1,133 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Policy describes how often and for how long a failing call is retried.
+// The wait before each retry grows exponentially from InitialInterval by Multiplier up to MaxInterval and is
+// randomized by up to Jitter (a fraction between 0 and 1) of itself so many clients don't retry in lockstep.
+type Policy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	Jitter          float64
+	// MaxAttempts is the most times the call is made, 0 means no limit
+	MaxAttempts int
+	// MaxElapsed is how long after the first attempt no more attempts are started, 0 means no limit
+	MaxElapsed time.Duration
+	// AttemptTimeout is how long a single attempt may take before it is abandoned and retried, 0 means no limit
+	AttemptTimeout time.Duration
+}
+
+// DefaultPolicy rides out short consul outages such as a leader election or a rolling restart
+var DefaultPolicy = Policy{
+	InitialInterval: 250 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+	MaxAttempts:     6,
+	MaxElapsed:      30 * time.Second,
+	AttemptTimeout:  10 * time.Second,
+}
+
+// Never makes a single attempt
+var Never = Policy{MaxAttempts: 1}
+
+// Do calls fn until it succeeds, the policy gives up or ctx is cancelled and returns the last error.
+// Each attempt is given a context that also expires after AttemptTimeout, so fn should give up once it is done.
+// When ctx is cancelled the returned error wraps ctx.Err().
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return cancelled(ctxErr, err)
+		}
+		err = p.attempt(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return cancelled(ctxErr, err)
+		}
+
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return err
+		}
+		wait := p.Backoff(attempt)
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return cancelled(ctx.Err(), err)
+		case <-t.C:
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.AttemptTimeout <= 0 {
+		return fn(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+	err := fn(attemptCtx)
+	if err != nil && ctx.Err() == nil && attemptCtx.Err() != nil {
+		//only this attempt expired, callers shouldn't mistake it for their own ctx ending
+		return fmt.Errorf("Attempt timed out after %v", p.AttemptTimeout)
+	}
+	return err
+}
+
+// Call runs fn on its own goroutine and returns its error, or ctx.Err() as soon as ctx is cancelled.  It is for
+// calls that can't be cancelled themselves, such as those of the consul client, which are abandoned rather than
+// stopped so fn must not write anything the caller reads once Call has returned ctx.Err().
+func Call(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
+
+// Backoff is how long to wait after the given attempt (starting at 1) fails
+func (p Policy) Backoff(attempt int) time.Duration {
+	wait := float64(p.InitialInterval)
+	for i := 1; i < attempt; i++ {
+		wait *= p.Multiplier
+		if p.MaxInterval > 0 && wait > float64(p.MaxInterval) {
+			break
+		}
+	}
+	if p.MaxInterval > 0 && wait > float64(p.MaxInterval) {
+		wait = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if wait < 0 {
+		return 0
+	}
+	return time.Duration(wait)
+}
+
+func cancelled(ctxErr, last error) error {
+	if last == nil {
+		return ctxErr
+	}
+	return fmt.Errorf("%w after: %v", ctxErr, last)
+}
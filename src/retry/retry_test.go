@@ -0,0 +1,214 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "first attempt", policy: Policy{InitialInterval: 100 * time.Millisecond, Multiplier: 2}, attempt: 1, want: 100 * time.Millisecond},
+		{name: "grows by the multiplier", policy: Policy{InitialInterval: 100 * time.Millisecond, Multiplier: 2}, attempt: 4, want: 800 * time.Millisecond},
+		{name: "capped", policy: Policy{InitialInterval: 100 * time.Millisecond, MaxInterval: 300 * time.Millisecond, Multiplier: 2}, attempt: 3, want: 300 * time.Millisecond},
+		{name: "stays capped", policy: Policy{InitialInterval: 100 * time.Millisecond, MaxInterval: 300 * time.Millisecond, Multiplier: 2}, attempt: 1000, want: 300 * time.Millisecond},
+		{name: "no interval", policy: Never, attempt: 3, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+				t.Fatalf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := Policy{InitialInterval: time.Second, MaxInterval: 4 * time.Second, Multiplier: 2, Jitter: 0.25}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 1, min: 750 * time.Millisecond, max: 1250 * time.Millisecond},
+		{attempt: 2, min: 1500 * time.Millisecond, max: 2500 * time.Millisecond},
+		{attempt: 5, min: 3 * time.Second, max: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if got := p.Backoff(tt.attempt); got < tt.min || got > tt.max {
+				t.Fatalf("Backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	fast := Policy{InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond, Multiplier: 2}
+	errFail := errors.New("fail")
+
+	tests := []struct {
+		name         string
+		policy       Policy
+		failures     int
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "succeeds first time", policy: fast, failures: 0, wantAttempts: 1},
+		{name: "succeeds after retries", policy: fast, failures: 3, wantAttempts: 4},
+		{name: "gives up after max attempts", policy: Policy{InitialInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 3}, failures: 10, wantAttempts: 3, wantErr: errFail},
+		{name: "never retries", policy: Never, failures: 10, wantAttempts: 1, wantErr: errFail},
+		{name: "gives up after max elapsed", policy: Policy{InitialInterval: time.Hour, MaxElapsed: time.Minute}, failures: 10, wantAttempts: 1, wantErr: errFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := tt.policy.Do(context.Background(), func(ctx context.Context) error {
+				attempts++
+				if attempts <= tt.failures {
+					return errFail
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Fatalf("Do error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Fatalf("Do made %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDoCancelled(t *testing.T) {
+	errFail := errors.New("fail")
+	slow := Policy{InitialInterval: time.Hour}
+
+	tests := []struct {
+		name         string
+		fn           func(cancel context.CancelFunc) func(ctx context.Context) error
+		preCancel    bool
+		wantAttempts int
+		wantLast     bool
+	}{
+		{
+			name:      "cancelled before the first attempt",
+			preCancel: true,
+			fn: func(cancel context.CancelFunc) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return errFail }
+			},
+			wantAttempts: 0,
+		},
+		{
+			name: "cancelled during an attempt",
+			fn: func(cancel context.CancelFunc) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					cancel()
+					return errFail
+				}
+			},
+			wantAttempts: 1,
+			wantLast:     true,
+		},
+		{
+			name: "cancelled while waiting to retry",
+			fn: func(cancel context.CancelFunc) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					time.AfterFunc(10*time.Millisecond, cancel)
+					return errFail
+				}
+			},
+			wantAttempts: 1,
+			wantLast:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.preCancel {
+				cancel()
+			}
+			attempts := 0
+			fn := tt.fn(cancel)
+			err := slow.Do(ctx, func(ctx context.Context) error {
+				attempts++
+				return fn(ctx)
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Do error = %v, want it to wrap context.Canceled", err)
+			}
+			if got := strings.Contains(err.Error(), errFail.Error()); got != tt.wantLast {
+				t.Fatalf("Do error = %v, want the last error included: %v", err, tt.wantLast)
+			}
+			if attempts != tt.wantAttempts {
+				t.Fatalf("Do made %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDoAttemptTimeout(t *testing.T) {
+	p := Policy{InitialInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 3, AttemptTimeout: 20 * time.Millisecond}
+
+	attempts := 0
+	start := time.Now()
+	err := p.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			//hang until the attempt is abandoned
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("Do made %d attempts, want 3", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Do took %v, hung attempts were not abandoned", elapsed)
+	}
+
+	err = Policy{MaxAttempts: 1, AttemptTimeout: 10 * time.Millisecond}.Do(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "Attempt timed out after 10ms") {
+		t.Fatalf("Do error = %v, want an attempt timeout that isn't the caller's deadline", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	errFail := errors.New("fail")
+	block := make(chan struct{})
+	defer close(block)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		fn      func() error
+		wantErr error
+	}{
+		{name: "success", timeout: time.Second, fn: func() error { return nil }},
+		{name: "error", timeout: time.Second, fn: func() error { return errFail }, wantErr: errFail},
+		{name: "abandoned", timeout: 10 * time.Millisecond, fn: func() error { <-block; return nil }, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := Call(ctx, tt.fn); err != tt.wantErr {
+				t.Fatalf("Call error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
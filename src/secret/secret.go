@@ -0,0 +1,269 @@
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// envelopePrefix starts every encrypted value, the rest is key id:base64(nonce + ciphertext)
+const envelopePrefix = "secret:v1:"
+
+// ErrNoKeyring is returned when an encrypted value is read without a keyring to decrypt it
+var ErrNoKeyring = errors.New("No keyring configured to decrypt the value")
+
+// Keyring holds the AES keys used to encrypt and decrypt config values.  New values are encrypted with the
+// primary key while any key in the ring can decrypt, so keys can be rotated without re-encrypting everything at once.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a Keyring from AES keys of 16, 24 or 32 bytes keyed by id, primary must be one of the ids
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("Primary key (%s) is not in the keyring", primary)
+	}
+
+	k := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("Invalid key id (%s)", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key (%s) %v", id, err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key (%s) %v", id, err)
+		}
+		k.keys[id] = gcm
+	}
+	return k, nil
+}
+
+// ParseKeyring reads a keyring with one id=base64key pair per line, the first key is the primary.
+// Blank lines and lines starting with # are ignored.
+func ParseKeyring(data []byte) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	primary := ""
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Could not parse keyring line %d, expected id=base64key", line)
+		}
+		id := strings.TrimSpace(parts[0])
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode key (%s) %v", id, err)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("Key (%s) is defined more than once", id)
+		}
+		keys[id] = key
+		if primary == "" {
+			primary = id
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if primary == "" {
+		return nil, errors.New("Keyring is empty")
+	}
+	return NewKeyring(primary, keys)
+}
+
+// LoadKeyring reads a keyring file in the format of ParseKeyring
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read keyring (%s) %v", path, err)
+	}
+	return ParseKeyring(data)
+}
+
+// IsEncrypted reports whether value is an envelope created by Encrypt
+func IsEncrypted(value []byte) bool {
+	_, ok := envelope(value)
+	return ok
+}
+
+// Encrypt seals the json value stored at key with the primary key.  The envelope is itself a json string so it
+// can be stored, exported and imported like any other value.  The key is authenticated along with the value so
+// an envelope copied to another key fails to decrypt.
+func (k *Keyring) Encrypt(key string, value []byte) ([]byte, error) {
+	gcm := k.keys[k.primary]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("Could not generate nonce: %v", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, value, []byte(strings.Trim(key, "/")))
+	return json.Marshal(envelopePrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed))
+}
+
+// Decrypt opens an envelope created by Encrypt for the same key and returns the original json value.
+// Values that are not envelopes are returned unchanged.
+func (k *Keyring) Decrypt(key string, value []byte) ([]byte, error) {
+	env, ok := envelope(value)
+	if !ok {
+		return value, nil
+	}
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+
+	parts := strings.SplitN(env, ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("Malformed encrypted value")
+	}
+	gcm, ok := k.keys[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("Value was encrypted with unknown key (%s)", parts[0])
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("Malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(strings.Trim(key, "/")))
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt value with key (%s) %v", parts[0], err)
+	}
+	return plain, nil
+}
+
+// EncryptKeys encrypts the values at the given slash separated keys of a json document, e.g. database/password.
+// Values that are already encrypted are left alone and it is an error for a key to be missing from the document.
+func (k *Keyring) EncryptKeys(data []byte, keys []string) ([]byte, error) {
+	return k.EncryptKeysReusing(data, nil, keys)
+}
+
+// EncryptKeysReusing is EncryptKeys keeping the envelopes already stored in current, a json document such as an
+// export of the namespace being imported into, for keys whose value is unchanged and that were encrypted with
+// the primary key.  Importing the same document again then leaves the encrypted keys as they are rather than
+// rewriting them with a new nonce.  current may be nil.
+func (k *Keyring) EncryptKeysReusing(data, current []byte, keys []string) ([]byte, error) {
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	var existing map[string]interface{}
+	if len(bytes.TrimSpace(current)) > 0 {
+		existing, err = decodeDocument(current)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range keys {
+		key = strings.Trim(key, "/")
+		path := strings.Split(key, "/")
+		parent, ok := walk(doc, path[:len(path)-1])
+		if !ok {
+			return nil, fmt.Errorf("Could not find key to encrypt: %s", key)
+		}
+		last := path[len(path)-1]
+		v, ok := parent[last]
+		if !ok {
+			return nil, fmt.Errorf("Could not find key to encrypt: %s", key)
+		}
+
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("Could not marshal config (%s) %v", key, err)
+		}
+		if IsEncrypted(value) {
+			continue
+		}
+		if sealed, ok := k.reusable(existing, path, key, value); ok {
+			parent[last] = json.RawMessage(sealed)
+			continue
+		}
+		sealed, err := k.Encrypt(key, value)
+		if err != nil {
+			return nil, err
+		}
+		parent[last] = json.RawMessage(sealed)
+	}
+	return json.Marshal(doc)
+}
+
+// reusable returns the envelope stored at path in existing if it was sealed with the primary key and holds value
+func (k *Keyring) reusable(existing map[string]interface{}, path []string, key string, value []byte) ([]byte, bool) {
+	parent, ok := walk(existing, path[:len(path)-1])
+	if !ok {
+		return nil, false
+	}
+	stored, err := json.Marshal(parent[path[len(path)-1]])
+	if err != nil {
+		return nil, false
+	}
+	env, ok := envelope(stored)
+	if !ok || !strings.HasPrefix(env, k.primary+":") {
+		//values sealed with an older key are encrypted again so rotation takes effect
+		return nil, false
+	}
+	plain, err := k.Decrypt(key, stored)
+	if err != nil {
+		return nil, false
+	}
+	var a, b bytes.Buffer
+	if json.Compact(&a, plain) != nil || json.Compact(&b, value) != nil || !bytes.Equal(a.Bytes(), b.Bytes()) {
+		return nil, false
+	}
+	return stored, true
+}
+
+func decodeDocument(data []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	doc := make(map[string]interface{})
+	if err := d.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Unable to parse json data: %v", err)
+	}
+	return doc, nil
+}
+
+// walk follows path through the nested objects of doc
+func walk(doc map[string]interface{}, path []string) (map[string]interface{}, bool) {
+	if doc == nil {
+		return nil, false
+	}
+	for _, p := range path {
+		sub, ok := doc[p].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		doc = sub
+	}
+	return doc, true
+}
+
+// envelope returns the key id:payload of an encrypted value
+func envelope(value []byte) (string, bool) {
+	if !bytes.HasPrefix(bytes.TrimSpace(value), []byte(`"`+envelopePrefix)) {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", false
+	}
+	return strings.TrimPrefix(s, envelopePrefix), true
+}
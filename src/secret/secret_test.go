@@ -0,0 +1,252 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, primary string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(primary, map[string][]byte{
+		"old": bytes.Repeat([]byte{1}, 32),
+		"new": bytes.Repeat([]byte{2}, 32),
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	oldRing := testKeyring(t, "old")
+	newRing := testKeyring(t, "new")
+	onlyNew, err := NewKeyring("new", map[string][]byte{"new": bytes.Repeat([]byte{2}, 32)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		encrypt *Keyring
+		decrypt *Keyring
+		key     string
+		readKey string
+		value   string
+		wantErr string
+	}{
+		{name: "roundtrip", encrypt: newRing, decrypt: newRing, key: "database/password", readKey: "database/password", value: `"hunter2"`},
+		{name: "slashes are trimmed", encrypt: newRing, decrypt: newRing, key: "/database/password/", readKey: "database/password", value: `{"a":1}`},
+		{name: "rotated key decrypts", encrypt: oldRing, decrypt: newRing, key: "database/password", readKey: "database/password", value: `"hunter2"`},
+		{name: "copied to another key", encrypt: newRing, decrypt: newRing, key: "database/password", readKey: "api/token", value: `"hunter2"`, wantErr: "Could not decrypt value with key (new)"},
+		{name: "unknown key", encrypt: oldRing, decrypt: onlyNew, key: "database/password", readKey: "database/password", value: `"hunter2"`, wantErr: "unknown key (old)"},
+		{name: "no keyring", encrypt: newRing, decrypt: nil, key: "database/password", readKey: "database/password", value: `"hunter2"`, wantErr: ErrNoKeyring.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := tt.encrypt.Encrypt(tt.key, []byte(tt.value))
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			if !IsEncrypted(sealed) {
+				t.Fatalf("IsEncrypted(%s) = false", sealed)
+			}
+			if bytes.Contains(sealed, []byte(tt.value)) {
+				t.Fatalf("envelope %s contains the plain value", sealed)
+			}
+
+			plain, err := tt.decrypt.Decrypt(tt.readKey, sealed)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Decrypt error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if string(plain) != tt.value {
+				t.Fatalf("Decrypt = %s, want %s", plain, tt.value)
+			}
+		})
+	}
+}
+
+func TestDecryptPlainAndMalformed(t *testing.T) {
+	k := testKeyring(t, "new")
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plain string", value: `"hunter2"`, want: `"hunter2"`},
+		{name: "plain number", value: `5432`, want: `5432`},
+		{name: "missing key id", value: `"secret:v1:nokeyid"`, wantErr: "Malformed encrypted value"},
+		{name: "bad base64", value: `"secret:v1:new:!!!"`, wantErr: "Malformed encrypted value"},
+		{name: "too short", value: `"secret:v1:new:AAAA"`, wantErr: "Malformed encrypted value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Decrypt("key", []byte(tt.value))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Decrypt error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Decrypt = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 16)
+	tests := []struct {
+		name    string
+		primary string
+		keys    map[string][]byte
+		wantErr string
+	}{
+		{name: "valid", primary: "a", keys: map[string][]byte{"a": key}},
+		{name: "missing primary", primary: "b", keys: map[string][]byte{"a": key}, wantErr: "Primary key (b)"},
+		{name: "colon in id", primary: "a:b", keys: map[string][]byte{"a:b": key}, wantErr: "Invalid key id (a:b)"},
+		{name: "bad key size", primary: "a", keys: map[string][]byte{"a": []byte("short")}, wantErr: "Invalid key (a)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.primary, tt.keys)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("NewKeyring: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("NewKeyring error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantPrimary string
+		wantErr     string
+	}{
+		{name: "first key is primary", data: "# keys\n\nnew=AgICAgICAgICAgICAgICAg==\nold=AQEBAQEBAQEBAQEBAQEBAQ==\n", wantPrimary: "new"},
+		{name: "empty", data: "# nothing\n", wantErr: "Keyring is empty"},
+		{name: "no separator", data: "new\n", wantErr: "line 1"},
+		{name: "duplicate", data: "a=AQEBAQEBAQEBAQEBAQEBAQ==\na=AQEBAQEBAQEBAQEBAQEBAQ==\n", wantErr: "more than once"},
+		{name: "bad base64", data: "a=!!!\n", wantErr: "Could not decode key (a)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKeyring([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseKeyring error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeyring: %v", err)
+			}
+			if k.primary != tt.wantPrimary {
+				t.Fatalf("primary = %s, want %s", k.primary, tt.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestEncryptKeysReusing(t *testing.T) {
+	oldRing := testKeyring(t, "old")
+	newRing := testKeyring(t, "new")
+
+	storedNew, err := newRing.Encrypt("database/password", []byte(`"hunter2"`))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	storedOld, err := oldRing.Encrypt("database/password", []byte(`"hunter2"`))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	current := func(stored []byte) string {
+		return `{"database": {"host": "db", "password": ` + string(stored) + `}}`
+	}
+
+	tests := []struct {
+		name      string
+		data      string
+		current   string
+		keys      []string
+		wantReuse []byte
+		wantPlain string
+		wantErr   string
+	}{
+		{name: "unchanged value keeps ciphertext", data: `{"database": {"host": "db", "password": "hunter2"}}`, current: current(storedNew), keys: []string{"database/password"}, wantReuse: storedNew, wantPlain: `"hunter2"`},
+		{name: "changed value is encrypted again", data: `{"database": {"password": "hunter3"}}`, current: current(storedNew), keys: []string{"database/password"}, wantPlain: `"hunter3"`},
+		{name: "older key is rotated", data: `{"database": {"password": "hunter2"}}`, current: current(storedOld), keys: []string{"/database/password/"}, wantPlain: `"hunter2"`},
+		{name: "nothing stored", data: `{"database": {"password": "hunter2"}}`, keys: []string{"database/password"}, wantPlain: `"hunter2"`},
+		{name: "already encrypted", data: current(storedOld), keys: []string{"database/password"}, wantReuse: storedOld, wantPlain: `"hunter2"`},
+		{name: "missing key", data: `{"database": {}}`, keys: []string{"database/password"}, wantErr: "Could not find key to encrypt: database/password"},
+		{name: "missing parent", data: `{}`, keys: []string{"database/password"}, wantErr: "Could not find key to encrypt: database/password"},
+		{name: "bad current", data: `{"database": {"password": "hunter2"}}`, current: `{`, keys: []string{"database/password"}, wantErr: "Unable to parse json data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := newRing.EncryptKeysReusing([]byte(tt.data), []byte(tt.current), tt.keys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EncryptKeysReusing error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EncryptKeysReusing: %v", err)
+			}
+
+			var doc struct {
+				Database map[string]json.RawMessage `json:"database"`
+			}
+			if err := json.Unmarshal(out, &doc); err != nil {
+				t.Fatalf("Unmarshal(%s): %v", out, err)
+			}
+			sealed := doc.Database["password"]
+			if !IsEncrypted(sealed) {
+				t.Fatalf("password %s was not encrypted", sealed)
+			}
+			if tt.wantReuse != nil && !bytes.Equal(sealed, tt.wantReuse) {
+				t.Fatalf("password = %s, want the stored ciphertext %s", sealed, tt.wantReuse)
+			}
+			if tt.wantReuse == nil && (bytes.Equal(sealed, storedNew) || bytes.Equal(sealed, storedOld)) {
+				t.Fatalf("password %s reused a stored ciphertext", sealed)
+			}
+			plain, err := newRing.Decrypt("database/password", sealed)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if string(plain) != tt.wantPlain {
+				t.Fatalf("Decrypt = %s, want %s", plain, tt.wantPlain)
+			}
+		})
+	}
+}
+
+func TestDecryptNilKeyringPlain(t *testing.T) {
+	var k *Keyring
+	got, err := k.Decrypt("key", []byte(`"plain"`))
+	if err != nil || string(got) != `"plain"` {
+		t.Fatalf("Decrypt = %s, %v", got, err)
+	}
+	_, err = k.Decrypt("key", []byte(`"secret:v1:new:AAAA"`))
+	if !errors.Is(err, ErrNoKeyring) {
+		t.Fatalf("Decrypt error = %v, want ErrNoKeyring", err)
+	}
+}